@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+// startBackups writes a backup to dir every interval until ctx is done. The
+// first one is taken one interval after startup, not immediately, so restart
+// loops don't fill the directory.
+func startBackups(ctx context.Context, wg *sync.WaitGroup, st store.Store, dir string, interval time.Duration, keep int) {
+	slog.Info("Scheduled backups enabled", "dir", dir, "interval", interval, "keep", keep)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				path, err := st.BackupToDir(dir, keep)
+				if err != nil {
+					slog.Error("Scheduled backup failed", "dir", dir, "err", err)
+					continue
+				}
+				slog.Info("Backup written", "path", path)
+			}
+		}
+	}()
+}
+
+func cmdRestore(args []string) int {
+	fs := newFlagSet("restore")
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to the YAML config file")
+	dbPath := fs.String("db", "", "path to the SQLite database to replace (overrides config and DB_PATH)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		return usageError("usage: reactionbot restore [--db path] <backup file>")
+	}
+	src := fs.Arg(0)
+	target := *dbPath
+	if target == "" {
+		cfg, err := loadConfig(*configPath)
+		if err != nil {
+			return failure("loading configuration", err)
+		}
+		if cfg.DatabaseURL != "" {
+			return usageError("restore only works with SQLite; restore Postgres with pg_restore")
+		}
+		target = cfg.DBPath
+	}
+
+	version, err := store.FileSchemaVersion(src)
+	if err != nil {
+		return failure("checking backup", err)
+	}
+	if latest := store.LatestSchemaVersion(); version > latest {
+		return failure("checking backup", fmt.Errorf("schema version %d is newer than this build supports (%d); upgrade reactionbot first", version, latest))
+	}
+	if version == 0 {
+		return failure("checking backup", fmt.Errorf("%s has no ReactionBot schema", src))
+	}
+
+	// Keep the database being replaced next to it, WAL included, then swap
+	// the backup in with a rename so the target is never half-written.
+	if _, err := os.Stat(target); err == nil {
+		aside := target + ".pre-restore-" + time.Now().UTC().Format("20060102-150405")
+		if err := store.BackupFile(target, aside); err != nil {
+			return failure("saving current database", err)
+		}
+		fmt.Println("current database saved to", aside)
+	}
+	tmp := filepath.Join(filepath.Dir(target), "."+filepath.Base(target)+".restore")
+	if err := copyFile(src, tmp); err != nil {
+		return failure("copying backup", err)
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		_ = os.Remove(tmp)
+		return failure("replacing database", err)
+	}
+	for _, suffix := range []string{"-wal", "-shm", "-journal"} {
+		_ = os.Remove(target + suffix)
+	}
+	fmt.Printf("restored %s (schema version %d) to %s; pending migrations run on next start\n", src, version, target)
+	return 0
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
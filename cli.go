@@ -0,0 +1,370 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sandeep97217890-droid/ReactionBot/handlers"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+const usageText = `Usage: reactionbot [command] [flags]
+
+Commands:
+  run                          Start the bot (default when no command is given)
+  config check                 Validate the configuration without connecting
+  login                        Log in an account interactively and export its session string
+  chats add|rm <chat_id…>      Add or remove monitored chats
+  chats ls                     List monitored chats
+  emojis add|rm prem|nprem <emoji…>
+                               Add or remove emojis from a reaction pool
+  emojis ls [prem|nprem]       List reaction pools
+  enable | disable             Turn auto-reactions on or off
+  db backup <path>             Write an online snapshot of the database
+  db vacuum                    Compact the database
+  export [--format json|yaml] [file]
+                               Write the full configuration to file (or stdout)
+  import [--mode merge|replace] [--dry-run] <file>
+                               Apply an exported configuration in one transaction
+  restore <backup>             Replace the database with a backup (bot must be stopped)
+
+Offline commands accept --config <file>, --db <path> and --tenant <id>
+(before any other arguments) and never touch Telegram.`
+
+var commands = map[string]func(args []string) int{
+	"run": func(args []string) int {
+		run(args)
+		return 0
+	},
+	"config":  cmdConfig,
+	"login":   cmdLogin,
+	"chats":   cmdChats,
+	"emojis":  cmdEmojis,
+	"enable":  func(args []string) int { return cmdSetEnabled(args, true) },
+	"disable": func(args []string) int { return cmdSetEnabled(args, false) },
+	"db":      cmdDB,
+	"export":  cmdExport,
+	"import":  cmdImport,
+	"restore": cmdRestore,
+}
+
+func dispatch(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		run(args)
+		return 0
+	}
+	switch args[0] {
+	case "help", "-h", "--help":
+		fmt.Println(usageText)
+		return 0
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s\n", args[0], usageText)
+		return 2
+	}
+	return cmd(args[1:])
+}
+
+func cmdConfig(args []string) int {
+	if len(args) == 0 || args[0] != "check" {
+		return usageError("usage: reactionbot config check [--config file]")
+	}
+	return runConfigCheck(args[1:])
+}
+
+func cmdChats(args []string) int {
+	if len(args) == 0 {
+		return usageError("usage: reactionbot chats add|rm|ls [chat_id…]")
+	}
+	st, cfg, rest, code := openOfflineStore(newFlagSet("chats "+args[0]), args[1:], args[0] == "add")
+	if st == nil {
+		return code
+	}
+	defer st.Close()
+
+	switch args[0] {
+	case "ls":
+		chats, err := st.GetChats()
+		if err != nil {
+			return failure("listing chats", err)
+		}
+		for _, id := range chats {
+			fmt.Println(id)
+		}
+	case "add", "rm":
+		if len(rest) == 0 {
+			return usageError("usage: reactionbot chats " + args[0] + " <chat_id…>")
+		}
+		var defaults store.ChatDefaults
+		if args[0] == "add" {
+			var err error
+			if defaults, err = cfg.ChatDefaults.Resolve(); err != nil {
+				return failure("reading chat_defaults", err)
+			}
+		}
+		for _, raw := range rest {
+			chatID, err := store.ParseChatID(raw)
+			if err != nil {
+				return usageError(fmt.Sprintf("invalid chat ID %q: %v", raw, err))
+			}
+			if args[0] == "add" {
+				err = st.AddChat(chatID, defaults)
+			} else {
+				err = st.RemoveChat(chatID)
+			}
+			if err != nil {
+				return failure("updating chat "+raw, err)
+			}
+		}
+		fmt.Printf("%d chat(s) updated\n", len(rest))
+	default:
+		return usageError(fmt.Sprintf("unknown chats subcommand %q", args[0]))
+	}
+	return 0
+}
+
+func cmdEmojis(args []string) int {
+	if len(args) == 0 {
+		return usageError("usage: reactionbot emojis add|rm|ls [prem|nprem] [emoji…]")
+	}
+	st, rest, code := openOffline("emojis "+args[0], args[1:])
+	if st == nil {
+		return code
+	}
+	defer st.Close()
+
+	switch args[0] {
+	case "ls":
+		pools := []string{"prem", "nprem"}
+		if len(rest) > 0 {
+			pools = rest[:1]
+		}
+		for _, pool := range pools {
+			get, ok := map[string]func() ([]string, error){"prem": st.GetPremEmojis, "nprem": st.GetNpremEmojis}[pool]
+			if !ok {
+				return usageError(fmt.Sprintf("unknown pool %q: use prem or nprem", pool))
+			}
+			emojis, err := get()
+			if err != nil {
+				return failure("listing emojis", err)
+			}
+			fmt.Printf("%s: %s\n", pool, strings.Join(emojis, " "))
+		}
+	case "add", "rm":
+		if len(rest) < 2 {
+			return usageError("usage: reactionbot emojis " + args[0] + " prem|nprem <emoji…>")
+		}
+		var apply func(string) error
+		switch rest[0] + " " + args[0] {
+		case "prem add":
+			apply = st.AddPremEmoji
+		case "prem rm":
+			apply = st.RemovePremEmoji
+		case "nprem add":
+			apply = st.AddNpremEmoji
+		case "nprem rm":
+			apply = st.RemoveNpremEmoji
+		default:
+			return usageError(fmt.Sprintf("unknown pool %q: use prem or nprem", rest[0]))
+		}
+		for _, emoji := range rest[1:] {
+			if args[0] == "add" && !handlers.IsValidReaction(emoji) {
+				return usageError(fmt.Sprintf("%s is not a valid Telegram reaction", emoji))
+			}
+			if err := apply(emoji); err != nil {
+				return failure("updating emoji "+emoji, err)
+			}
+		}
+		fmt.Printf("%d emoji(s) updated in %s pool\n", len(rest)-1, rest[0])
+	default:
+		return usageError(fmt.Sprintf("unknown emojis subcommand %q", args[0]))
+	}
+	return 0
+}
+
+func cmdSetEnabled(args []string, enabled bool) int {
+	name := "disable"
+	if enabled {
+		name = "enable"
+	}
+	st, _, code := openOffline(name, args)
+	if st == nil {
+		return code
+	}
+	defer st.Close()
+	if err := st.SetEnabled(enabled); err != nil {
+		return failure(name+" auto-reactions", err)
+	}
+	fmt.Printf("auto-reactions %sd\n", name)
+	return 0
+}
+
+func cmdDB(args []string) int {
+	if len(args) == 0 {
+		return usageError("usage: reactionbot db backup <path> | db vacuum")
+	}
+	st, rest, code := openOffline("db "+args[0], args[1:])
+	if st == nil {
+		return code
+	}
+	defer st.Close()
+
+	switch args[0] {
+	case "backup":
+		if len(rest) != 1 {
+			return usageError("usage: reactionbot db backup <path>")
+		}
+		if err := st.Backup(rest[0]); err != nil {
+			return failure("backing up database", err)
+		}
+		fmt.Println("backup written to", rest[0])
+	case "vacuum":
+		if err := st.Vacuum(); err != nil {
+			return failure("vacuuming database", err)
+		}
+		fmt.Println("database vacuumed")
+	default:
+		return usageError(fmt.Sprintf("unknown db subcommand %q", args[0]))
+	}
+	return 0
+}
+
+func cmdExport(args []string) int {
+	fs := newFlagSet("export")
+	format := fs.String("format", "json", "output format: json or yaml")
+	st, rest, code := openOfflineFlags(fs, args)
+	if st == nil {
+		return code
+	}
+	defer st.Close()
+	if len(rest) > 1 {
+		return usageError("usage: reactionbot export [--format json|yaml] [file]")
+	}
+
+	snap, err := st.Export()
+	if err != nil {
+		return failure("exporting configuration", err)
+	}
+	data, err := snap.Marshal(*format)
+	if err != nil {
+		return usageError(err.Error())
+	}
+	if len(rest) == 0 {
+		os.Stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(rest[0], data, 0o600); err != nil {
+		return failure("writing "+rest[0], err)
+	}
+	fmt.Printf("%d chat(s), %d user(s) exported to %s\n", len(snap.Chats), len(snap.Users), rest[0])
+	return 0
+}
+
+func cmdImport(args []string) int {
+	fs := newFlagSet("import")
+	mode := fs.String("mode", "merge", "merge keeps entries missing from the file; replace removes them")
+	dryRun := fs.Bool("dry-run", false, "print the changes without applying them")
+	st, rest, code := openOfflineFlags(fs, args)
+	if st == nil {
+		return code
+	}
+	defer st.Close()
+	importMode, err := store.ParseImportMode(*mode)
+	if err != nil {
+		return usageError(err.Error())
+	}
+	if len(rest) != 1 {
+		return usageError("usage: reactionbot import [--mode merge|replace] [--dry-run] <file>")
+	}
+
+	raw, err := os.ReadFile(rest[0])
+	if err != nil {
+		return failure("reading "+rest[0], err)
+	}
+	snap, err := store.ParseSnapshot(raw)
+	if err != nil {
+		return failure("reading "+rest[0], err)
+	}
+	changes, err := st.Import(snap, importMode, *dryRun)
+	if err != nil {
+		return failure("importing configuration", err)
+	}
+	for _, c := range changes {
+		fmt.Println(c)
+	}
+	switch {
+	case len(changes) == 0:
+		fmt.Println("nothing to change")
+	case *dryRun:
+		fmt.Printf("%d change(s) previewed, nothing applied\n", len(changes))
+	default:
+		fmt.Printf("%d change(s) applied\n", len(changes))
+	}
+	return 0
+}
+
+// openOffline parses the shared --config/--db/--tenant flags and opens the store
+// without touching Telegram. On failure it returns a nil store and the exit
+// code to use.
+func openOffline(name string, args []string) (store.Store, []string, int) {
+	return openOfflineFlags(newFlagSet(name), args)
+}
+
+// openOfflineFlags is openOffline for commands that register flags of their
+// own on fs first.
+func openOfflineFlags(fs *flag.FlagSet, args []string) (store.Store, []string, int) {
+	st, _, rest, code := openOfflineStore(fs, args, false)
+	return st, rest, code
+}
+
+// openOfflineStore is openOfflineFlags that also returns the configuration.
+// It is loaded when it names the database, when --tenant picks a tenant,
+// which must be one it lists, or when withConfig is set, and is nil
+// otherwise.
+func openOfflineStore(fs *flag.FlagSet, args []string, withConfig bool) (store.Store, *Config, []string, int) {
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to the YAML config file")
+	dbPath := fs.String("db", "", "SQLite path or postgres:// URL (overrides config, DB_PATH and DATABASE_URL)")
+	tenant := fs.String("tenant", store.DefaultTenant, "tenant whose chats, pools and settings to use")
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, nil, 2
+	}
+	var cfg *Config
+	if *dbPath == "" || *tenant != store.DefaultTenant || withConfig {
+		var err error
+		if cfg, err = loadConfig(*configPath); err != nil {
+			return nil, nil, nil, failure("loading configuration", err)
+		}
+	}
+	if *tenant != store.DefaultTenant && !cfg.hasTenant(*tenant) {
+		return nil, nil, nil, usageError(fmt.Sprintf("unknown tenant %q: add it under tenants in the configuration first", *tenant))
+	}
+	path := *dbPath
+	if path == "" {
+		path = cfg.StoreDSN()
+	}
+	st, err := store.New(path)
+	if err != nil {
+		return nil, nil, nil, failure("opening database "+describeDSN(path), err)
+	}
+	if *tenant != store.DefaultTenant {
+		st = st.ForTenant(*tenant)
+		if err := st.InitTenant(); err != nil {
+			st.Close()
+			return nil, nil, nil, failure("initialising tenant "+*tenant, err)
+		}
+	}
+	return st, cfg, fs.Args(), 0
+}
+
+func usageError(msg string) int {
+	fmt.Fprintln(os.Stderr, msg)
+	return 2
+}
+
+func failure(what string, err error) int {
+	fmt.Fprintf(os.Stderr, "error %s: %v\n", what, err)
+	return 1
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sandeep97217890-droid/ReactionBot/handlers"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	defaultDBPath     = "reactions.db"
+	defaultBackupKeep = 7
+)
+
+type Config struct {
+	AppID       int32            `yaml:"app_id"`
+	AppHash     string           `yaml:"app_hash"`
+	BotToken    string           `yaml:"bot_token"`
+	Owners      []int64          `yaml:"owners"`
+	AuditChatID int64            `yaml:"audit_chat_id"`
+	DBPath      string           `yaml:"db_path"`
+	DatabaseURL string           `yaml:"database_url"`
+	Sessions    []SessionConfig  `yaml:"sessions"`
+	Tenants     []TenantConfig   `yaml:"tenants"`
+	Quotas      QuotaSettings    `yaml:"quotas"`
+	Reactions   ReactionSettings `yaml:"reactions"`
+	// ChatDefaults are applied to chats added with /addchat or chats add.
+	ChatDefaults ChatDefaultsConfig `yaml:"chat_defaults"`
+	Log          LogSettings        `yaml:"log"`
+	Backup       BackupSettings     `yaml:"backup"`
+}
+
+type SessionConfig struct {
+	Session string `yaml:"session"`
+	Premium bool   `yaml:"premium"`
+	// Tenant is the tenant whose chats the session reacts in; empty means
+	// the default tenant.
+	Tenant string `yaml:"tenant"`
+	// HourlyQuota and DailyQuota override the quotas section for this
+	// session; zero inherits it.
+	HourlyQuota int `yaml:"hourly_quota"`
+	DailyQuota  int `yaml:"daily_quota"`
+}
+
+// QuotaSettings caps the reactions each session sends, by account kind.
+type QuotaSettings struct {
+	Premium QuotaConfig `yaml:"premium"`
+	Regular QuotaConfig `yaml:"regular"`
+}
+
+// QuotaConfig is one set of limits; zero means no cap.
+type QuotaConfig struct {
+	Hourly int `yaml:"hourly"`
+	Daily  int `yaml:"daily"`
+}
+
+// SessionQuota is the quota sc runs under: its own limits where set, the
+// ones for its account kind otherwise.
+func (c *Config) SessionQuota(sc SessionConfig) handlers.Quota {
+	q := c.Quotas.Regular
+	if sc.Premium {
+		q = c.Quotas.Premium
+	}
+	if sc.HourlyQuota > 0 {
+		q.Hourly = sc.HourlyQuota
+	}
+	if sc.DailyQuota > 0 {
+		q.Daily = sc.DailyQuota
+	}
+	return handlers.Quota{Hourly: q.Hourly, Daily: q.Daily}
+}
+
+// TenantConfig declares a team with its own chats, pools, settings, users
+// and sessions. The top-level owners and audit_chat_id form the default
+// tenant.
+type TenantConfig struct {
+	ID          string  `yaml:"id"`
+	Owners      []int64 `yaml:"owners"`
+	AuditChatID int64   `yaml:"audit_chat_id"`
+}
+
+var tenantIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// AllTenants returns the default tenant followed by the configured ones.
+func (c *Config) AllTenants() []TenantConfig {
+	return append([]TenantConfig{{ID: store.DefaultTenant, Owners: c.Owners, AuditChatID: c.AuditChatID}}, c.Tenants...)
+}
+
+// hasTenant reports whether id is a valid tenant ID that c serves.
+func (c *Config) hasTenant(id string) bool {
+	if !tenantIDPattern.MatchString(id) {
+		return false
+	}
+	return slices.ContainsFunc(c.AllTenants(), func(t TenantConfig) bool { return t.ID == id })
+}
+
+// SessionTenant is the tenant sc belongs to.
+func (sc SessionConfig) SessionTenant() string {
+	if sc.Tenant == "" {
+		return store.DefaultTenant
+	}
+	return sc.Tenant
+}
+
+// BackupSettings configures scheduled backups. They are off while Dir is
+// empty.
+type BackupSettings struct {
+	Dir      string `yaml:"dir"`
+	Interval string `yaml:"interval"`
+	Keep     int    `yaml:"keep"`
+}
+
+// IntervalDuration parses Interval, defaulting to one day.
+func (b BackupSettings) IntervalDuration() (time.Duration, error) {
+	if b.Interval == "" {
+		return 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(b.Interval)
+	if err != nil {
+		return 0, err
+	}
+	if d < time.Minute {
+		return 0, fmt.Errorf("%s is shorter than one minute", d)
+	}
+	return d, nil
+}
+
+// ChatDefaultsConfig holds the settings new chats start with. Fields left
+// unset keep store.DefaultChatDefaults.
+type ChatDefaultsConfig struct {
+	Paused   bool       `yaml:"paused"`
+	Reactors int        `yaml:"reactors"`
+	Comments bool       `yaml:"comments"`
+	Post     RuleConfig `yaml:"post"`
+	Comment  RuleConfig `yaml:"comment"`
+	Albums   string     `yaml:"albums"`
+}
+
+// RuleConfig is a store.Rule where zero values mean the default.
+type RuleConfig struct {
+	Probability int    `yaml:"probability"`
+	Filter      string `yaml:"filter"`
+}
+
+func (r RuleConfig) rule() (store.Rule, error) {
+	out := store.DefaultRule
+	if r.Probability != 0 {
+		out.Probability = r.Probability
+	}
+	if r.Filter != "" {
+		f, err := store.ParseFilter(r.Filter)
+		if err != nil {
+			return out, err
+		}
+		out.Filter = f
+	}
+	return out, out.Validate()
+}
+
+// Resolve fills in the defaults and checks the result.
+func (d ChatDefaultsConfig) Resolve() (store.ChatDefaults, error) {
+	out := store.DefaultChatDefaults
+	out.Paused, out.Reactors, out.Comments = d.Paused, d.Reactors, d.Comments
+	var errs []error
+	var err error
+	if out.Post, err = d.Post.rule(); err != nil {
+		errs = append(errs, fmt.Errorf("post: %w", err))
+	}
+	if out.Comment, err = d.Comment.rule(); err != nil {
+		errs = append(errs, fmt.Errorf("comment: %w", err))
+	}
+	if d.Albums != "" {
+		if out.Albums, err = store.ParseAlbumMode(d.Albums); err != nil {
+			errs = append(errs, fmt.Errorf("albums: %w", err))
+		}
+	}
+	if d.Reactors < 0 {
+		errs = append(errs, errors.New("reactors must not be negative"))
+	}
+	return out, errors.Join(errs...)
+}
+
+// ReactionSettings sizes the worker pool that sends reactions.
+type ReactionSettings struct {
+	Workers int `yaml:"workers"`
+	Queue   int `yaml:"queue"`
+	// ShutdownGrace is how long queued reactions may keep going after a
+	// shutdown signal.
+	ShutdownGrace string `yaml:"shutdown_grace"`
+}
+
+// GraceDuration parses ShutdownGrace, defaulting to ten seconds.
+func (r ReactionSettings) GraceDuration() (time.Duration, error) {
+	if r.ShutdownGrace == "" {
+		return 10 * time.Second, nil
+	}
+	d, err := time.ParseDuration(r.ShutdownGrace)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s is negative", d)
+	}
+	return d, nil
+}
+
+type LogSettings struct {
+	Level       string `yaml:"level"`
+	Format      string `yaml:"format"`
+	GogramLevel string `yaml:"gogram_level"`
+}
+
+// loadConfig reads the optional YAML file at path and then applies
+// environment variables on top of it, so env always wins over the file.
+func loadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+	if path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(raw))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+	if err := cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	if cfg.DBPath == "" {
+		cfg.DBPath = defaultDBPath
+	}
+	if cfg.Backup.Keep == 0 {
+		cfg.Backup.Keep = defaultBackupKeep
+	}
+	return cfg, nil
+}
+
+func (c *Config) applyEnv() error {
+	if v := os.Getenv("APP_ID"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("APP_ID must be a valid integer: %w", err)
+		}
+		c.AppID = int32(id)
+	}
+	if v := os.Getenv("APP_HASH"); v != "" {
+		c.AppHash = v
+	}
+	if v := os.Getenv("BOT_TOKEN"); v != "" {
+		c.BotToken = v
+	}
+	if v := os.Getenv("OWNER_IDS"); v != "" {
+		ids, err := parseOwnerIDs(v)
+		if err != nil {
+			return fmt.Errorf("OWNER_IDS: %w", err)
+		}
+		c.Owners = ids
+	}
+	if v := os.Getenv("AUDIT_CHAT_ID"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return fmt.Errorf("AUDIT_CHAT_ID must be a valid integer: %w", err)
+		}
+		c.AuditChatID = id
+	}
+	if v := os.Getenv("DB_PATH"); v != "" {
+		c.DBPath = v
+	}
+	if v := os.Getenv("DATABASE_URL"); v != "" {
+		c.DatabaseURL = v
+	}
+	if v, ok := os.LookupEnv("PREM_SESSIONS"); ok && strings.TrimSpace(v) != "" {
+		c.replaceSessions(true, parseSessions(v))
+	}
+	if v, ok := os.LookupEnv("NPREM_SESSIONS"); ok && strings.TrimSpace(v) != "" {
+		c.replaceSessions(false, parseSessions(v))
+	}
+	for name, dst := range map[string]*int{
+		"QUOTA_HOURLY":      &c.Quotas.Regular.Hourly,
+		"QUOTA_DAILY":       &c.Quotas.Regular.Daily,
+		"PREM_QUOTA_HOURLY": &c.Quotas.Premium.Hourly,
+		"PREM_QUOTA_DAILY":  &c.Quotas.Premium.Daily,
+		"REACTION_WORKERS":  &c.Reactions.Workers,
+		"REACTION_QUEUE":    &c.Reactions.Queue,
+	} {
+		if v := os.Getenv(name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s must be a valid integer: %w", name, err)
+			}
+			*dst = n
+		}
+	}
+	if v := os.Getenv("SHUTDOWN_GRACE"); v != "" {
+		c.Reactions.ShutdownGrace = v
+	}
+	if v := os.Getenv("BACKUP_DIR"); v != "" {
+		c.Backup.Dir = v
+	}
+	if v := os.Getenv("BACKUP_INTERVAL"); v != "" {
+		c.Backup.Interval = v
+	}
+	if v := os.Getenv("BACKUP_KEEP"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("BACKUP_KEEP must be a valid integer: %w", err)
+		}
+		c.Backup.Keep = n
+	}
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		c.Log.Level = v
+	}
+	if v := os.Getenv("LOG_FORMAT"); v != "" {
+		c.Log.Format = v
+	}
+	if v := os.Getenv("GOGRAM_LOG_LEVEL"); v != "" {
+		c.Log.GogramLevel = v
+	}
+	return nil
+}
+
+// replaceSessions swaps every file-declared session of one kind for the ones
+// given in the environment.
+func (c *Config) replaceSessions(premium bool, raw []string) {
+	kept := c.Sessions[:0]
+	for _, s := range c.Sessions {
+		if s.Premium != premium {
+			kept = append(kept, s)
+		}
+	}
+	for _, s := range raw {
+		kept = append(kept, SessionConfig{Session: s, Premium: premium})
+	}
+	c.Sessions = kept
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+	if c.AppID <= 0 {
+		errs = append(errs, errors.New("app_id (APP_ID) is required and must be positive"))
+	}
+	if c.AppHash == "" {
+		errs = append(errs, errors.New("app_hash (APP_HASH) is required"))
+	}
+	if len(c.Sessions) == 0 && c.BotToken == "" {
+		errs = append(errs, errors.New("no sessions or bot token configured: set sessions/bot_token, or PREM_SESSIONS, NPREM_SESSIONS and/or BOT_TOKEN"))
+	}
+	if c.BotToken != "" && len(c.Owners) == 0 && len(c.Tenants) == 0 {
+		errs = append(errs, errors.New("owners (OWNER_IDS) must contain at least one user ID when a bot token is configured"))
+	}
+	for i, id := range c.Owners {
+		if id <= 0 {
+			errs = append(errs, fmt.Errorf("owners[%d]: %d is not a valid user ID", i, id))
+		}
+	}
+	tenants := map[string]bool{store.DefaultTenant: true}
+	ownerOf := make(map[int64]string)
+	for _, id := range c.Owners {
+		ownerOf[id] = store.DefaultTenant
+	}
+	for i, t := range c.Tenants {
+		switch {
+		case t.ID == store.DefaultTenant:
+			errs = append(errs, fmt.Errorf("tenants[%d]: %q is the tenant of the top-level owners and cannot be declared", i, t.ID))
+			continue
+		case !tenantIDPattern.MatchString(t.ID):
+			errs = append(errs, fmt.Errorf("tenants[%d]: id %q must be lowercase letters, digits, - or _", i, t.ID))
+			continue
+		case tenants[t.ID]:
+			errs = append(errs, fmt.Errorf("tenants[%d]: duplicate id %q", i, t.ID))
+			continue
+		}
+		tenants[t.ID] = true
+		if len(t.Owners) == 0 {
+			errs = append(errs, fmt.Errorf("tenants[%d] (%s): owners must contain at least one user ID", i, t.ID))
+		}
+		for j, id := range t.Owners {
+			if id <= 0 {
+				errs = append(errs, fmt.Errorf("tenants[%d].owners[%d]: %d is not a valid user ID", i, j, id))
+				continue
+			}
+			if other, dup := ownerOf[id]; dup && other != t.ID {
+				errs = append(errs, fmt.Errorf("tenants[%d] (%s): owner %d is already an owner of tenant %s", i, t.ID, id, other))
+			}
+			ownerOf[id] = t.ID
+		}
+	}
+	seen := make(map[string]int, len(c.Sessions))
+	for i, s := range c.Sessions {
+		if strings.TrimSpace(s.Session) == "" {
+			errs = append(errs, fmt.Errorf("sessions[%d]: session string is empty", i))
+			continue
+		}
+		if j, dup := seen[s.Session]; dup {
+			errs = append(errs, fmt.Errorf("sessions[%d]: duplicate of sessions[%d] (%s)", i, j, sessionID(s.Session)))
+		}
+		seen[s.Session] = i
+		if !tenants[s.SessionTenant()] {
+			errs = append(errs, fmt.Errorf("sessions[%d]: unknown tenant %q", i, s.Tenant))
+		}
+		if s.HourlyQuota < 0 || s.DailyQuota < 0 {
+			errs = append(errs, fmt.Errorf("sessions[%d]: hourly_quota and daily_quota must not be negative", i))
+		}
+	}
+	for _, q := range []struct {
+		name string
+		q    QuotaConfig
+	}{{"quotas.regular (QUOTA_HOURLY, QUOTA_DAILY)", c.Quotas.Regular}, {"quotas.premium (PREM_QUOTA_HOURLY, PREM_QUOTA_DAILY)", c.Quotas.Premium}} {
+		if q.q.Hourly < 0 || q.q.Daily < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", q.name))
+		}
+	}
+	if _, err := parseLogConfig(c.Log.Level, c.Log.Format, c.Log.GogramLevel); err != nil {
+		errs = append(errs, fmt.Errorf("log: %w", err))
+	}
+	if c.Reactions.Workers < 0 || c.Reactions.Queue < 0 {
+		errs = append(errs, errors.New("reactions.workers (REACTION_WORKERS) and reactions.queue (REACTION_QUEUE) must not be negative"))
+	}
+	if _, err := c.ChatDefaults.Resolve(); err != nil {
+		for _, line := range strings.Split(err.Error(), "\n") {
+			errs = append(errs, fmt.Errorf("chat_defaults.%s", line))
+		}
+	}
+	if _, err := c.Reactions.GraceDuration(); err != nil {
+		errs = append(errs, fmt.Errorf("reactions.shutdown_grace (SHUTDOWN_GRACE): %w", err))
+	}
+	if _, err := c.Backup.IntervalDuration(); err != nil {
+		errs = append(errs, fmt.Errorf("backup.interval (BACKUP_INTERVAL): %w", err))
+	}
+	if c.DatabaseURL != "" && !strings.HasPrefix(c.DatabaseURL, "postgres://") && !strings.HasPrefix(c.DatabaseURL, "postgresql://") {
+		errs = append(errs, errors.New("database_url (DATABASE_URL) must be a postgres:// or postgresql:// URL"))
+	}
+	if c.DatabaseURL != "" && c.Backup.Dir != "" {
+		errs = append(errs, errors.New("backup.dir (BACKUP_DIR) only works with SQLite; back up Postgres with pg_dump"))
+	}
+	if c.Backup.Keep < 0 {
+		errs = append(errs, errors.New("backup.keep (BACKUP_KEEP) must not be negative"))
+	}
+	return errors.Join(errs...)
+}
+
+// StoreDSN is what store.New opens: the Postgres URL when one is set,
+// otherwise the SQLite path.
+func (c *Config) StoreDSN() string {
+	if c.DatabaseURL != "" {
+		return c.DatabaseURL
+	}
+	return c.DBPath
+}
+
+// describeDSN names a store for humans: SQLite paths as they are, Postgres
+// URLs without credentials or options.
+func describeDSN(dsn string) string {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		return dsn
+	}
+	if u, err := url.Parse(dsn); err == nil {
+		return "postgres://" + u.Host + u.Path
+	}
+	return "postgres"
+}
+
+// Secrets lists every configured credential that must never reach the logs.
+func (c *Config) Secrets() []string {
+	out := []string{c.BotToken, c.AppHash, c.DatabaseURL}
+	if u, err := url.Parse(c.DatabaseURL); err == nil && u.User != nil {
+		if pw, ok := u.User.Password(); ok {
+			out = append(out, pw)
+		}
+	}
+	for _, s := range c.Sessions {
+		out = append(out, s.Session)
+	}
+	return out
+}
+
+func runConfigCheck(args []string) int {
+	fs := newFlagSet("config check")
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to the YAML config file")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "config error:", err)
+		return 1
+	}
+	if err := cfg.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, "config is invalid:")
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintln(os.Stderr, "  -", line)
+		}
+		return 1
+	}
+	prem := 0
+	for _, s := range cfg.Sessions {
+		if s.Premium {
+			prem++
+		}
+	}
+	fmt.Printf("config OK: %d session(s) (%d premium), bot=%v, %d owner(s), %d tenant(s), db=%s\n",
+		len(cfg.Sessions), prem, cfg.BotToken != "", len(cfg.Owners), len(cfg.AllTenants()), describeDSN(cfg.StoreDSN()))
+	return 0
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("reactionbot "+name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+// access resolves a sender's role in one tenant on every update, so grants
+// and revokes take effect without a restart. IDs from OWNER_IDS, or a
+// tenant's owners, are bootstrap owners and cannot be revoked from chat.
+type access struct {
+	st        store.Store
+	bootstrap map[int64]struct{}
+	// owners maps the bootstrap owners of every tenant to their tenant, so
+	// one tenant cannot grant a role to another's owner.
+	owners map[int64]string
+}
+
+func newAccess(st store.Store, ownerIDs []int64, owners map[int64]string) *access {
+	a := &access{st: st, bootstrap: make(map[int64]struct{}, len(ownerIDs)), owners: owners}
+	for _, id := range ownerIDs {
+		a.bootstrap[id] = struct{}{}
+	}
+	return a
+}
+
+func (a *access) isBootstrap(userID int64) bool {
+	_, ok := a.bootstrap[userID]
+	return ok
+}
+
+func (a *access) role(userID int64) (store.Role, bool) {
+	if a.isBootstrap(userID) {
+		return store.RoleOwner, true
+	}
+	role, ok, err := a.st.GetUserRole(userID)
+	if err != nil {
+		slog.Error("Failed to look up user role", "user_id", userID, "err", err)
+		return "", false
+	}
+	return role, ok
+}
+
+func (a *access) allowed(userID int64, min store.Role) bool {
+	role, ok := a.role(userID)
+	return ok && role.AtLeast(min)
+}
+
+// require wraps a command handler so it only runs for senders holding at
+// least min. Unknown users are ignored silently, as before; known users with
+// too little access are told which role they need.
+func (a *access) require(min store.Role, h func(m *telegram.NewMessage) error) func(m *telegram.NewMessage) error {
+	return func(m *telegram.NewMessage) error {
+		role, ok := a.role(m.SenderID())
+		if !ok {
+			return nil
+		}
+		if !role.AtLeast(min) {
+			_, _ = m.Reply(fmt.Sprintf("⛔ This command needs the <b>%s</b> role (you are %s).", min, role))
+			return nil
+		}
+		return h(m)
+	}
+}
+
+func (a *access) managedChats(userID int64) []int64 {
+	chats, err := a.st.ManagedChats(userID)
+	if err != nil {
+		slog.Error("Failed to look up managed chats", "user_id", userID, "err", err)
+	}
+	return chats
+}
+
+// requireChat wraps a per-chat command whose first argument is a chat ID. It
+// runs for admins and above, and for delegated managers of that chat only.
+func (a *access) requireChat(usage string, h func(m *telegram.NewMessage, chatID int64) error) func(m *telegram.NewMessage) error {
+	return func(m *telegram.NewMessage) error {
+		userID := m.SenderID()
+		role, known := a.role(userID)
+		managed := a.managedChats(userID)
+		if !known && len(managed) == 0 {
+			return nil
+		}
+		chatID, err := store.ParseChatID(firstArg(m))
+		if err != nil {
+			_, _ = m.Reply(usage)
+			return nil
+		}
+		if !role.AtLeast(store.RoleAdmin) && !slices.Contains(managed, chatID) {
+			_, _ = m.Reply(fmt.Sprintf("⛔ You don't manage chat <code>%d</code>.", chatID))
+			return nil
+		}
+		return h(m, chatID)
+	}
+}
+
+// known only lets through callback queries from users holding any role.
+func (a *access) known() telegram.Filter {
+	return telegram.CustomCallback(func(cb *telegram.CallbackQuery) bool {
+		_, ok := a.role(cb.GetSenderID())
+		return ok
+	})
+}
+
+func registerUserCommands(client *telegram.Client, st store.Store, a *access, au *auditor) {
+	client.On("cmd:grant", a.require(store.RoleOwner, func(m *telegram.NewMessage) error {
+		args := strings.Fields(m.Args())
+		if len(args) != 2 {
+			_, _ = m.Reply("Usage: /grant &lt;user_id&gt; owner|admin|viewer")
+			return nil
+		}
+		userID, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || userID <= 0 {
+			_, _ = m.Reply("❌ Invalid user ID: must be a positive number.")
+			return nil
+		}
+		role, err := store.ParseRole(strings.ToLower(args[1]))
+		if err != nil {
+			_, _ = m.Reply("❌ " + err.Error())
+			return nil
+		}
+		if a.isBootstrap(userID) {
+			_, _ = m.Reply("ℹ️ That user is an owner from OWNER_IDS; their role can only change in the configuration.")
+			return nil
+		}
+		if tenant, ok := a.owners[userID]; ok && tenant != st.Tenant() {
+			_, _ = m.Reply("❌ That user is an owner of another tenant.")
+			return nil
+		}
+		before, _ := a.role(userID)
+		if err := st.SetUserRole(userID, role, m.SenderID()); errors.Is(err, store.ErrOtherTenant) {
+			_, _ = m.Reply("❌ That user already has a role in another tenant.")
+			return nil
+		} else if err != nil {
+			_, _ = m.Reply("❌ Failed to grant role: " + err.Error())
+			return err
+		}
+		au.recordMsg(m, "grant", string(before), string(role))
+		_, _ = m.Reply(fmt.Sprintf("✅ User <code>%d</code> is now %s.", userID, role))
+		return nil
+	}))
+
+	client.On("cmd:revoke", a.require(store.RoleOwner, func(m *telegram.NewMessage) error {
+		userID, err := strconv.ParseInt(strings.TrimSpace(m.Args()), 10, 64)
+		if err != nil {
+			_, _ = m.Reply("Usage: /revoke &lt;user_id&gt;")
+			return nil
+		}
+		if a.isBootstrap(userID) {
+			_, _ = m.Reply("ℹ️ Owners from OWNER_IDS cannot be revoked from chat; remove them from the configuration.")
+			return nil
+		}
+		role, _ := a.role(userID)
+		before := string(role)
+		if managed := managedState(a, userID); managed != "" {
+			before = strings.TrimPrefix(before+"; manages "+managed, "; ")
+		}
+		removed, err := st.RemoveUser(userID)
+		if err != nil {
+			_, _ = m.Reply("❌ Failed to revoke: " + err.Error())
+			return err
+		}
+		if !removed {
+			_, _ = m.Reply(fmt.Sprintf("ℹ️ User <code>%d</code> has no role and manages no chat.", userID))
+			return nil
+		}
+		au.recordMsg(m, "revoke", before, "")
+		_, _ = m.Reply(fmt.Sprintf("✅ Revoked all access for <code>%d</code>.", userID))
+		return nil
+	}))
+
+	client.On("cmd:users", a.require(store.RoleOwner, func(m *telegram.NewMessage) error {
+		users, err := st.ListUsers()
+		if err != nil {
+			_, _ = m.Reply("❌ Error: " + err.Error())
+			return err
+		}
+		lines := make([]string, 0, len(a.bootstrap)+len(users))
+		for id := range a.bootstrap {
+			lines = append(lines, fmt.Sprintf("👑 <code>%d</code> — owner (config)", id))
+		}
+		slices.Sort(lines)
+		for _, u := range users {
+			lines = append(lines, fmt.Sprintf("%s <code>%d</code> — %s (by <code>%d</code>)", roleIcon(u.Role), u.ID, u.Role, u.GrantedBy))
+		}
+		_, _ = m.Reply("👥 <b>Users</b>\n" + strings.Join(lines, "\n"))
+		return nil
+	}))
+}
+
+func firstArg(m *telegram.NewMessage) string {
+	if f := strings.Fields(m.Args()); len(f) > 0 {
+		return f[0]
+	}
+	return ""
+}
+
+func roleIcon(r store.Role) string {
+	switch r {
+	case store.RoleOwner:
+		return "👑"
+	case store.RoleAdmin:
+		return "🛠"
+	}
+	return "👁"
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+// albumWindow is how long the items of an album are collected before
+// picking the ones to react to. Telegram sends them back to back.
+const albumWindow = 1500 * time.Millisecond
+
+type albumKey struct {
+	tenant    string
+	chatID    int64
+	groupedID int64
+}
+
+// albumBuffer gathers the messages of each album as they arrive.
+type albumBuffer struct {
+	mu      sync.Mutex
+	pending map[albumKey][]*telegram.NewMessage
+}
+
+// add buffers m under key. The first item of an album starts the window;
+// when it closes, done gets every item seen.
+func (b *albumBuffer) add(key albumKey, m *telegram.NewMessage, done func([]*telegram.NewMessage)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending == nil {
+		b.pending = make(map[albumKey][]*telegram.NewMessage)
+	}
+	items, started := b.pending[key]
+	b.pending[key] = append(items, m)
+	if started {
+		return
+	}
+	time.AfterFunc(albumWindow, func() {
+		b.mu.Lock()
+		items := b.pending[key]
+		delete(b.pending, key)
+		b.mu.Unlock()
+		done(items)
+	})
+}
+
+// pickAlbumItem returns the item of an album to react to: the first one, or
+// for AlbumCaption the first with a caption, falling back to the first.
+func pickAlbumItem(mode store.AlbumMode, items []*telegram.NewMessage) *telegram.NewMessage {
+	items = slices.SortedFunc(slices.Values(items), func(a, b *telegram.NewMessage) int { return int(a.ID - b.ID) })
+	if mode == store.AlbumCaption {
+		for _, m := range items {
+			if strings.TrimSpace(m.Text()) != "" {
+				return m
+			}
+		}
+	}
+	return items[0]
+}
+
+func registerAlbumCommands(client *telegram.Client, st store.Store, a *access, au *auditor) {
+	const usage = "Usage: /albums &lt;chat_id&gt; first|caption|all\nReact to the first item of each album, the one with the caption, or every item."
+	client.On("cmd:albums", a.requireChat(usage, func(m *telegram.NewMessage, chatID int64) error {
+		args := strings.Fields(m.Args())
+		if len(args) != 2 {
+			_, _ = m.Reply(usage)
+			return nil
+		}
+		mode, err := store.ParseAlbumMode(args[1])
+		if err != nil {
+			_, _ = m.Reply(usage)
+			return nil
+		}
+		chat, ok, err := st.GetChat(chatID)
+		if err != nil {
+			_, _ = m.Reply("❌ Error: " + err.Error())
+			return err
+		}
+		if !ok {
+			_, _ = m.Reply(fmt.Sprintf("❌ Chat %d is not in the auto-react list.", chatID))
+			return nil
+		}
+		if err := st.SetChatAlbums(chatID, mode); err != nil {
+			_, _ = m.Reply("❌ Failed to set album mode: " + err.Error())
+			return err
+		}
+		au.recordMsg(m, "albums", string(chat.Albums), string(mode))
+		_, _ = m.Reply(fmt.Sprintf("✅ Chat %d: albums get reactions on %s.", chatID, albumLabel(mode)))
+		return nil
+	}))
+}
+
+func albumLabel(mode store.AlbumMode) string {
+	switch mode {
+	case store.AlbumCaption:
+		return "the captioned item"
+	case store.AlbumAll:
+		return "every item"
+	}
+	return "the first item"
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"testing"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+// albumItem is an album message with the given ID and caption.
+func albumItem(id int32, caption string) *telegram.NewMessage {
+	return &telegram.NewMessage{ID: id, Message: &telegram.MessageObj{ID: id, Message: caption, GroupedID: 1}}
+}
+
+func TestPickAlbumItem(t *testing.T) {
+	tests := []struct {
+		name  string
+		mode  store.AlbumMode
+		items []*telegram.NewMessage
+		want  int32
+	}{
+		{"first", store.AlbumFirst, []*telegram.NewMessage{albumItem(10, ""), albumItem(11, "caption")}, 10},
+		{"first by ID", store.AlbumFirst, []*telegram.NewMessage{albumItem(12, ""), albumItem(10, ""), albumItem(11, "")}, 10},
+		{"caption", store.AlbumCaption, []*telegram.NewMessage{albumItem(10, ""), albumItem(12, "late"), albumItem(11, "caption")}, 11},
+		{"caption of blanks only", store.AlbumCaption, []*telegram.NewMessage{albumItem(11, " \n"), albumItem(10, "")}, 10},
+		{"no caption", store.AlbumCaption, []*telegram.NewMessage{albumItem(11, ""), albumItem(10, "")}, 10},
+		{"single item", store.AlbumCaption, []*telegram.NewMessage{albumItem(10, "")}, 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pickAlbumItem(tt.mode, tt.items); got.ID != tt.want {
+				t.Errorf("pickAlbumItem() = %d, want %d", got.ID, tt.want)
+			}
+		})
+	}
+}
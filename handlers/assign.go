@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+func registerAssignCommands(client *telegram.Client, st store.Store, sessions []*Session, a *access, au *auditor) {
+	client.On("cmd:assign", a.requireChat("Usage: /assign &lt;chat_id&gt; &lt;session|#tag…&gt;", func(m *telegram.NewMessage, chatID int64) error {
+		ids := selectors(m)
+		if len(ids) == 0 {
+			_, _ = m.Reply("Usage: /assign &lt;chat_id&gt; &lt;session|#tag…&gt;\nSession IDs and tags are listed by /sessions.")
+			return nil
+		}
+		if _, unknown := selectSessions(sessions, ids); len(unknown) > 0 {
+			_, _ = m.Reply("❌ Unknown session(s): " + html.EscapeString(strings.Join(unknown, ", ")) + "\nSee /sessions.")
+			return nil
+		}
+		has, err := st.HasChat(chatID)
+		if err != nil {
+			_, _ = m.Reply("❌ Error: " + err.Error())
+			return err
+		}
+		if !has {
+			_, _ = m.Reply(fmt.Sprintf("❌ Chat %d is not in the auto-react list.", chatID))
+			return nil
+		}
+		before := assignedState(st, chatID)
+		if err := st.AssignSessions(chatID, ids); err != nil {
+			_, _ = m.Reply("❌ Failed to assign sessions: " + err.Error())
+			return err
+		}
+		after := assignedState(st, chatID)
+		au.recordMsg(m, "assign", before, after)
+		_, _ = m.Reply(fmt.Sprintf("✅ Chat %d: %s react.", chatID, after))
+		return nil
+	}))
+
+	client.On("cmd:unassign", a.requireChat("Usage: /unassign &lt;chat_id&gt; [session|#tag…]", func(m *telegram.NewMessage, chatID int64) error {
+		ids := selectors(m)
+		before := assignedState(st, chatID)
+		if err := st.UnassignSessions(chatID, ids); err != nil {
+			_, _ = m.Reply("❌ Failed to unassign sessions: " + err.Error())
+			return err
+		}
+		after := assignedState(st, chatID)
+		au.recordMsg(m, "unassign", before, after)
+		_, _ = m.Reply(fmt.Sprintf("✅ Chat %d: %s react.", chatID, after))
+		return nil
+	}))
+}
+
+// selectors returns the session selectors following the chat ID.
+func selectors(m *telegram.NewMessage) []string {
+	fields := strings.Fields(m.Args())[1:]
+	for i, f := range fields {
+		fields[i] = normalizeSelector(f)
+	}
+	return fields
+}
+
+// assignedState describes who reacts in a chat, for replies and the audit log.
+func assignedState(st store.Store, chatID int64) string {
+	ids, err := st.ChatSessions(chatID)
+	switch {
+	case err != nil:
+		return "error: " + err.Error()
+	case len(ids) == 0:
+		return "all member sessions"
+	}
+	return "sessions " + strings.Join(ids, ", ")
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+const auditPageSize = 10
+
+// auditor records every mutating bot command and, when a log chat is
+// configured, forwards each entry there as well.
+type auditor struct {
+	st     store.Store
+	client *telegram.Client
+	chatID int64
+}
+
+func (au *auditor) record(userID int64, command, args, before, after string) {
+	e := store.AuditEntry{At: time.Now(), UserID: userID, Command: command, Args: args, Before: before, After: after}
+	if err := au.st.AddAudit(e); err != nil {
+		slog.Error("Failed to write audit entry", "user_id", userID, "command", command, "err", err)
+	}
+	if au.chatID == 0 {
+		return
+	}
+	if _, err := au.client.SendMessage(au.chatID, "📝 "+formatAudit(e)); err != nil {
+		slog.Warn("Failed to forward audit entry", "chat_id", au.chatID, "command", command, "err", err)
+	}
+}
+
+func (au *auditor) recordMsg(m *telegram.NewMessage, command, before, after string) {
+	au.record(m.SenderID(), command, strings.TrimSpace(m.Args()), before, after)
+}
+
+func formatAudit(e store.AuditEntry) string {
+	line := fmt.Sprintf("%s · <code>%d</code> · /%s", e.At.UTC().Format("2006-01-02 15:04"), e.UserID, e.Command)
+	if e.Args != "" {
+		line += " " + html.EscapeString(e.Args)
+	}
+	if e.Before != "" || e.After != "" {
+		line += fmt.Sprintf("\n    %s → %s", html.EscapeString(orDash(e.Before)), html.EscapeString(orDash(e.After)))
+	}
+	return line
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "—"
+	}
+	return s
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func chatState(st store.Store, chatID int64) string {
+	has, err := st.HasChat(chatID)
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	if !has {
+		return ""
+	}
+	active, err := st.IsChatActive(chatID)
+	switch {
+	case err != nil:
+		return "error: " + err.Error()
+	case active:
+		return "active"
+	}
+	return "paused"
+}
+
+func poolState(list func() ([]string, error)) string {
+	emojis, err := list()
+	if err != nil {
+		return "error: " + err.Error()
+	}
+	return strings.Join(emojis, " ")
+}
+
+func registerAuditCommands(client *telegram.Client, st store.Store, a *access) {
+	client.On("cmd:audit", a.require(store.RoleAdmin, func(m *telegram.NewMessage) error {
+		page := 1
+		if arg := strings.TrimSpace(m.Args()); arg != "" {
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				_, _ = m.Reply("Usage: /audit [page]")
+				return nil
+			}
+			page = n
+		}
+		total, err := st.CountAudit()
+		if err != nil {
+			_, _ = m.Reply("❌ Error: " + err.Error())
+			return err
+		}
+		if total == 0 {
+			_, _ = m.Reply("📝 The audit log is empty.")
+			return nil
+		}
+		pages := (total + auditPageSize - 1) / auditPageSize
+		page = min(page, pages)
+		entries, err := st.ListAudit(auditPageSize, (page-1)*auditPageSize)
+		if err != nil {
+			_, _ = m.Reply("❌ Error: " + err.Error())
+			return err
+		}
+		lines := make([]string, len(entries))
+		for i, e := range entries {
+			lines[i] = formatAudit(e)
+		}
+		footer := ""
+		if page < pages {
+			footer = fmt.Sprintf("\n\nOlder entries: /audit %d", page+1)
+		}
+		_, _ = m.Reply(fmt.Sprintf("📝 <b>Audit log</b> (page %d/%d, %d entries)\n\n%s%s",
+			page, pages, total, strings.Join(lines, "\n"), footer))
+		return nil
+	}))
+}
@@ -0,0 +1,371 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+const (
+	// backfillMax caps the messages one backfill fetches.
+	backfillMax = 1000
+	// backfillPace is the least time between two backfilled messages that
+	// get reactions, so old posts don't crowd out the live ones.
+	backfillPace = 3 * time.Second
+	// backfillReportEvery rate-limits the edits of the progress message.
+	backfillReportEvery = 10 * time.Second
+)
+
+var errShuttingDown = errors.New("the bot is shutting down")
+
+// BackfillRequest says how far back a backfill goes: the last Count
+// messages, or those sent after Since, at most backfillMax either way.
+type BackfillRequest struct {
+	Count int
+	Since time.Time
+}
+
+// BackfillProgress counts the messages a backfill has been through.
+type BackfillProgress struct {
+	Total     int // messages fetched
+	Done      int // messages checked so far
+	Reacted   int // messages that got reactions
+	Reactions int // reactions queued
+	Skipped   int // messages already reacted to
+}
+
+// Backfill reacts to the recent history of a monitored chat as if its
+// posts had just arrived: each goes through the chat's rule, the dedupe
+// with live updates, album handling, quotas and the reaction queue. The
+// history is read through a connected session of the tenant that is in the
+// chat, oldest message first, and reacted to at most once per
+// backfillPace. Messages that session already reacted to are skipped.
+// progress is called after every message. Backfill stops when ctx is done,
+// when the Reactor shuts down, or when the chat or auto-reactions are
+// turned off.
+func (r *Reactor) Backfill(ctx context.Context, st store.Store, chatID int64, req BackfillRequest, progress func(BackfillProgress)) (BackfillProgress, error) {
+	var p BackfillProgress
+	chat, ok, err := st.GetChat(chatID)
+	if err != nil {
+		return p, err
+	}
+	if !ok {
+		return p, store.ErrChatNotFound
+	}
+	msgs, err := r.history(st, chatID, req)
+	if err != nil {
+		return p, err
+	}
+	p.Total = len(msgs)
+	progress(p)
+	var last time.Time
+	for _, items := range albumUnits(chat.Albums, msgs) {
+		if chat, err = r.backfillTarget(ctx, st, chatID); err != nil {
+			return p, err
+		}
+		if !r.markSeen(st.Tenant(), chatID, items) || slices.ContainsFunc(items, reactedTo) {
+			p.Skipped += len(items)
+			p.Done += len(items)
+			progress(p)
+			continue
+		}
+		if wait := time.Until(last.Add(backfillPace)); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return p, ctx.Err()
+			case <-r.ctx.Done():
+				return p, errShuttingDown
+			case <-time.After(wait):
+			}
+		}
+		m := items[0]
+		if len(items) > 1 {
+			m = pickAlbumItem(chat.Albums, items)
+		}
+		if n := r.react(st, chat, store.KindPost, m); n > 0 {
+			p.Reacted++
+			p.Reactions += n
+			last = time.Now()
+		}
+		p.Done += len(items)
+		progress(p)
+	}
+	return p, nil
+}
+
+// backfillTarget reloads the chat before each backfilled message, so a
+// backfill honours rule changes and stops when reactions are turned off.
+func (r *Reactor) backfillTarget(ctx context.Context, st store.Store, chatID int64) (store.Chat, error) {
+	switch {
+	case ctx.Err() != nil:
+		return store.Chat{}, ctx.Err()
+	case r.ctx.Err() != nil:
+		return store.Chat{}, errShuttingDown
+	}
+	enabled, err := st.IsEnabled()
+	if err != nil {
+		return store.Chat{}, err
+	}
+	if !enabled {
+		return store.Chat{}, errors.New("auto-reactions were turned off")
+	}
+	chat, ok, err := st.GetChat(chatID)
+	switch {
+	case err != nil:
+		return store.Chat{}, err
+	case !ok:
+		return store.Chat{}, store.ErrChatNotFound
+	case !chat.Enabled:
+		return store.Chat{}, errors.New("the chat was paused")
+	}
+	return chat, nil
+}
+
+// history fetches the messages req asks for, oldest first, through the
+// first session of the tenant that can read the chat.
+func (r *Reactor) history(st store.Store, chatID int64, req BackfillRequest) ([]*telegram.NewMessage, error) {
+	err := errors.New("no session in the chat is connected")
+	for _, sess := range connectedSessions(sessionsOf(r.sessions, st.Tenant())) {
+		if r.members.excluded(sess.ID, chatID) {
+			continue
+		}
+		client := sess.Client()
+		if client == nil {
+			continue
+		}
+		page := func(limit, offset int32) ([]telegram.NewMessage, error) {
+			return client.GetHistory(chatID, &telegram.HistoryOption{Limit: limit, Offset: offset})
+		}
+		var msgs []*telegram.NewMessage
+		if msgs, err = fetchHistory(page, req); err == nil {
+			slog.Info("Backfilling chat", "tenant", st.Tenant(), "chat_id", chatID, "session", sess.ID, "messages", len(msgs))
+			return msgs, nil
+		}
+		slog.Warn("Failed to read chat history", "session", sess.ID, "chat_id", chatID, "err", err)
+	}
+	return nil, err
+}
+
+// historyPage returns up to limit messages older than the message offset,
+// or the latest ones for 0, newest first, as Client.GetHistory does.
+type historyPage func(limit, offset int32) ([]telegram.NewMessage, error)
+
+// fetchHistory pages back through a chat until req is satisfied. Service
+// messages are left out.
+func fetchHistory(page historyPage, req BackfillRequest) ([]*telegram.NewMessage, error) {
+	limit := backfillMax
+	if req.Count > 0 {
+		limit = min(req.Count, backfillMax)
+	}
+	var out []*telegram.NewMessage
+	var offset int32
+	for len(out) < limit {
+		want := min(limit-len(out), 100)
+		msgs, err := page(int32(want), offset)
+		if err != nil {
+			return nil, err
+		}
+		for i := range msgs {
+			m := &msgs[i]
+			if !req.Since.IsZero() && int64(m.Date()) < req.Since.Unix() {
+				slices.Reverse(out)
+				return out, nil
+			}
+			if !m.IsService() && len(out) < limit {
+				out = append(out, m)
+			}
+		}
+		if len(msgs) < want {
+			break
+		}
+		offset = msgs[len(msgs)-1].ID
+	}
+	slices.Reverse(out)
+	return out, nil
+}
+
+// albumUnits groups consecutive items of an album unless every item is
+// reacted to.
+func albumUnits(mode store.AlbumMode, msgs []*telegram.NewMessage) [][]*telegram.NewMessage {
+	var units [][]*telegram.NewMessage
+	for _, m := range msgs {
+		if n := len(units); n > 0 && mode != store.AlbumAll && m.Message.GroupedID != 0 && units[n-1][0].Message.GroupedID == m.Message.GroupedID {
+			units[n-1] = append(units[n-1], m)
+			continue
+		}
+		units = append(units, []*telegram.NewMessage{m})
+	}
+	return units
+}
+
+// markSeen records items as handled and reports whether none of them was
+// already.
+func (r *Reactor) markSeen(tenant string, chatID int64, items []*telegram.NewMessage) bool {
+	fresh := true
+	for _, m := range items {
+		if _, loaded := r.seen.LoadOrStore(seenKey{tenant, chatID, m.ID}, struct{}{}); loaded {
+			fresh = false
+		}
+	}
+	return fresh
+}
+
+// reactedTo reports whether the session that fetched m had reacted to it.
+func reactedTo(m *telegram.NewMessage) bool {
+	if m.Message.Reactions == nil {
+		return false
+	}
+	return slices.ContainsFunc(m.Message.Reactions.Results, func(c *telegram.ReactionCount) bool { return c.ChosenOrder > 0 })
+}
+
+// parseBackfillArg reads a message count, or a lookback such as 12h or 3d.
+func parseBackfillArg(arg string, now time.Time) (BackfillRequest, error) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > backfillMax {
+			return BackfillRequest{}, fmt.Errorf("count must be between 1 and %d", backfillMax)
+		}
+		return BackfillRequest{Count: n}, nil
+	}
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(arg, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(arg)
+	}
+	if err != nil || d <= 0 {
+		return BackfillRequest{}, fmt.Errorf("%q is neither a message count nor a duration such as 12h or 3d", arg)
+	}
+	return BackfillRequest{Since: now.Add(-d)}, nil
+}
+
+func formatBackfill(p BackfillProgress) string {
+	return fmt.Sprintf("%d/%d messages checked, %d reacted to with %d reaction(s), %d already reacted to", p.Done, p.Total, p.Reacted, p.Reactions, p.Skipped)
+}
+
+// backfillJob is the backfill running in a tenant. One runs at a time so
+// backfills don't add up against the quotas and the queue.
+type backfillJob struct {
+	chatID int64
+	cancel context.CancelFunc
+}
+
+func registerBackfillCommands(client *telegram.Client, st store.Store, a *access, au *auditor, reactor *Reactor) {
+	const usage = "Usage: /backfill &lt;chat_id&gt; &lt;count|since&gt;\nReacts to the last messages of a chat, e.g. <code>200</code>, or those of the last <code>12h</code> or <code>3d</code>.\n/backfill cancel stops it."
+	var (
+		mu      sync.Mutex
+		running *backfillJob
+	)
+
+	start := a.requireChat(usage, func(m *telegram.NewMessage, chatID int64) error {
+		args := strings.Fields(strings.ToLower(m.Args()))
+		if len(args) != 2 {
+			_, _ = m.Reply(usage)
+			return nil
+		}
+		req, err := parseBackfillArg(args[1], time.Now())
+		if err != nil {
+			_, _ = m.Reply("❌ " + html.EscapeString(err.Error()) + ".\n" + usage)
+			return nil
+		}
+		chat, ok, err := st.GetChat(chatID)
+		if err != nil {
+			_, _ = m.Reply("❌ Error: " + err.Error())
+			return err
+		}
+		if !ok {
+			_, _ = m.Reply(fmt.Sprintf("❌ Chat %d is not in the auto-react list.", chatID))
+			return nil
+		}
+		if !chat.Enabled {
+			_, _ = m.Reply(fmt.Sprintf("❌ Chat %d is paused. Resume it with /resumechat first.", chatID))
+			return nil
+		}
+
+		mu.Lock()
+		if running != nil {
+			busy := running.chatID
+			mu.Unlock()
+			_, _ = m.Reply(fmt.Sprintf("⏳ A backfill of chat %d is already running. Stop it with /backfill cancel.", busy))
+			return nil
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		job := &backfillJob{chatID: chatID, cancel: cancel}
+		running = job
+		mu.Unlock()
+
+		au.recordMsg(m, "backfill", "", "started")
+		status, _ := m.Reply(fmt.Sprintf("⏳ Backfill of chat %d: reading the history…", chatID))
+		report := func(text string) {
+			if status != nil {
+				_, _ = status.Edit(text)
+			}
+		}
+		go func() {
+			defer func() {
+				cancel()
+				mu.Lock()
+				if running == job {
+					running = nil
+				}
+				mu.Unlock()
+			}()
+			var lastReport time.Time
+			p, err := reactor.Backfill(ctx, st, chatID, req, func(p BackfillProgress) {
+				if now := time.Now(); now.Sub(lastReport) >= backfillReportEvery {
+					lastReport = now
+					report(fmt.Sprintf("⏳ Backfill of chat %d: %s.", chatID, formatBackfill(p)))
+				}
+			})
+			switch {
+			case err == nil:
+				report(fmt.Sprintf("✅ Backfill of chat %d done: %s.", chatID, formatBackfill(p)))
+			case errors.Is(err, context.Canceled):
+				report(fmt.Sprintf("🛑 Backfill of chat %d cancelled: %s.", chatID, formatBackfill(p)))
+			default:
+				slog.Warn("Backfill stopped", "tenant", st.Tenant(), "chat_id", chatID, "err", err)
+				report(fmt.Sprintf("❌ Backfill of chat %d stopped: %s\n%s.", chatID, html.EscapeString(err.Error()), formatBackfill(p)))
+			}
+		}()
+		return nil
+	})
+
+	client.On("cmd:backfill", func(m *telegram.NewMessage) error {
+		if !strings.EqualFold(firstArg(m), "cancel") {
+			return start(m)
+		}
+		userID := m.SenderID()
+		role, known := a.role(userID)
+		managed := a.managedChats(userID)
+		if !known && len(managed) == 0 {
+			return nil
+		}
+		mu.Lock()
+		job := running
+		mu.Unlock()
+		if job == nil {
+			_, _ = m.Reply("ℹ️ No backfill is running.")
+			return nil
+		}
+		if !role.AtLeast(store.RoleAdmin) && !slices.Contains(managed, job.chatID) {
+			_, _ = m.Reply(fmt.Sprintf("⛔ You don't manage chat <code>%d</code>.", job.chatID))
+			return nil
+		}
+		job.cancel()
+		au.recordMsg(m, "backfill", fmt.Sprintf("running in %d", job.chatID), "cancelled")
+		_, _ = m.Reply(fmt.Sprintf("🛑 Cancelling the backfill of chat %d…", job.chatID))
+		return nil
+	})
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/amarnathcjd/gogram/telegram"
+)
+
+// fakeHistory is a chat history of messages 1 to n, one a minute up to
+// latest, paged newest first like Client.GetHistory. Messages in service
+// are service messages.
+type fakeHistory struct {
+	n       int32
+	latest  time.Time
+	service map[int32]bool
+	pages   int
+}
+
+func (h *fakeHistory) date(id int32) time.Time {
+	return h.latest.Add(-time.Duration(h.n-id) * time.Minute)
+}
+
+func (h *fakeHistory) page(limit, offset int32) ([]telegram.NewMessage, error) {
+	h.pages++
+	top := h.n
+	if offset != 0 {
+		top = offset - 1
+	}
+	var out []telegram.NewMessage
+	for id := top; id > 0 && int32(len(out)) < limit; id-- {
+		m := telegram.NewMessage{ID: id, Message: &telegram.MessageObj{ID: id, Date: int32(h.date(id).Unix())}}
+		if h.service[id] {
+			m.OriginalUpdate = &telegram.MessageService{ID: id}
+		}
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func ids(msgs []*telegram.NewMessage) []int32 {
+	var out []int32
+	for _, m := range msgs {
+		out = append(out, m.ID)
+	}
+	return out
+}
+
+// span returns the IDs from first to last.
+func span(first, last int32) []int32 {
+	var out []int32
+	for id := first; id <= last; id++ {
+		out = append(out, id)
+	}
+	return out
+}
+
+func TestFetchHistory(t *testing.T) {
+	latest := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		n       int32
+		service []int32
+		req     BackfillRequest
+		want    []int32
+		pages   int
+	}{
+		{"count", 500, nil, BackfillRequest{Count: 3}, span(498, 500), 1},
+		{"count across pages", 500, nil, BackfillRequest{Count: 150}, span(351, 500), 2},
+		{"count past the start", 40, nil, BackfillRequest{Count: 200}, span(1, 40), 1},
+		{"count above the cap", 2000, nil, BackfillRequest{Count: 5000}, span(1001, 2000), 10},
+		{"service messages don't count", 500, []int32{499, 500}, BackfillRequest{Count: 3}, span(496, 498), 2},
+		// 150 minutes back is message 350, in the middle of the second page.
+		{"since cutoff mid-page", 500, nil, BackfillRequest{Since: latest.Add(-150 * time.Minute)}, span(350, 500), 2},
+		{"since with a count", 500, nil, BackfillRequest{Count: 10, Since: latest.Add(-150 * time.Minute)}, span(491, 500), 1},
+		{"since before the start", 40, nil, BackfillRequest{Since: latest.Add(-24 * time.Hour)}, span(1, 40), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &fakeHistory{n: tt.n, latest: latest, service: make(map[int32]bool)}
+			for _, id := range tt.service {
+				h.service[id] = true
+			}
+			msgs, err := fetchHistory(h.page, tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(msgs); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fetchHistory() = %d messages %v…, want %d from %d", len(got), got[:min(len(got), 3)], len(tt.want), tt.want[0])
+			}
+			if h.pages != tt.pages {
+				t.Errorf("fetchHistory() read %d pages, want %d", h.pages, tt.pages)
+			}
+		})
+	}
+}
+
+func TestParseBackfillArg(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		arg     string
+		want    BackfillRequest
+		wantErr bool
+	}{
+		{"200", BackfillRequest{Count: 200}, false},
+		{"1000", BackfillRequest{Count: 1000}, false},
+		{"12h", BackfillRequest{Since: now.Add(-12 * time.Hour)}, false},
+		{"90m", BackfillRequest{Since: now.Add(-90 * time.Minute)}, false},
+		{"3d", BackfillRequest{Since: now.Add(-72 * time.Hour)}, false},
+		{"0", BackfillRequest{}, true},
+		{"1001", BackfillRequest{}, true},
+		{"-5", BackfillRequest{}, true},
+		{"0d", BackfillRequest{}, true},
+		{"-2h", BackfillRequest{}, true},
+		{"xd", BackfillRequest{}, true},
+		{"week", BackfillRequest{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			got, err := parseBackfillArg(tt.arg, now)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseBackfillArg(%q) = %+v, %v, want %+v, error %v", tt.arg, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+func registerBackupCommands(client *telegram.Client, st store.Store, a *access, au *auditor) {
+	// The database holds every role and chat, so the file always goes to the
+	// owner's private chat, never to the group the command was sent from.
+	client.On("cmd:backup", a.require(store.RoleOwner, func(m *telegram.NewMessage) error {
+		dir, err := os.MkdirTemp("", "reactionbot-backup-")
+		if err != nil {
+			_, _ = m.Reply("❌ Backup failed: " + err.Error())
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		name := "reactions-" + time.Now().UTC().Format("20060102-150405") + ".db"
+		path := filepath.Join(dir, name)
+		if err := st.Backup(path); err != nil {
+			_, _ = m.Reply("❌ Backup failed: " + err.Error())
+			return err
+		}
+		version, _ := st.SchemaVersion()
+		_, err = client.SendMedia(m.SenderID(), path, &telegram.MediaOptions{
+			FileName:      name,
+			ForceDocument: true,
+			Caption:       fmt.Sprintf("💾 Database backup (schema version %d). Restore with <code>reactionbot restore</code>.", version),
+		})
+		if err != nil {
+			_, _ = m.Reply("❌ Failed to send backup: " + err.Error() + "\nStart a private chat with the bot first.")
+			return err
+		}
+		au.recordMsg(m, "backup", "", name)
+		if m.ChatID() != m.SenderID() {
+			_, _ = m.Reply("✅ Backup sent to you in private.")
+		}
+		return nil
+	}))
+}
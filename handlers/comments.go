@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+// discussionRetry is how long the reaction path waits before looking up a
+// channel's discussion group again after finding none.
+const discussionRetry = 10 * time.Minute
+
+var errNotBroadcast = errors.New("only broadcast channels have comments")
+
+// target finds the monitored chat a message in chatID belongs to: the chat
+// itself for posts, or the channel whose discussion group chatID is for
+// comments.
+func (r *Reactor) target(st store.Store, chatID int64) (store.Chat, store.Kind, bool, error) {
+	chat, ok, err := st.GetChat(chatID)
+	if err != nil || ok {
+		return chat, store.KindPost, ok, err
+	}
+	parent, ok, err := st.DiscussionParent(chatID)
+	if err != nil || !ok {
+		return store.Chat{}, "", false, err
+	}
+	chat, ok, err = st.GetChat(parent)
+	return chat, store.KindComment, ok, err
+}
+
+// findDiscussion looks up the discussion group of a channel with comments
+// on, through the session that just saw one of its posts, and remembers it.
+// Lookups run in the background and at most once per discussionRetry.
+func (r *Reactor) findDiscussion(sess *Session, st store.Store, chatID int64) {
+	key := seenKey{tenant: st.Tenant(), chatID: chatID}
+	now := time.Now()
+	if last, ok := r.lookups.Load(key); ok && now.Sub(last.(time.Time)) < discussionRetry {
+		return
+	}
+	r.lookups.Store(key, now)
+	client := sess.Client()
+	if client == nil {
+		return
+	}
+	go func() {
+		discussion, err := linkedChat(client, chatID)
+		switch {
+		case err != nil:
+			slog.Warn("Failed to look up discussion group", "session", sess.ID, "chat_id", chatID, "err", err)
+		case discussion == 0:
+			slog.Info("Channel has no discussion group", "tenant", key.tenant, "chat_id", chatID)
+		default:
+			if r.health.record("set_chat_discussion", st.SetChatDiscussion(chatID, discussion), "chat_id", chatID) {
+				slog.Info("Found discussion group", "tenant", key.tenant, "chat_id", chatID, "discussion_id", discussion)
+			}
+		}
+	}()
+}
+
+// linkedChat returns the canonical ID of the discussion group linked to a
+// broadcast channel, or 0 when it has none.
+func linkedChat(client *telegram.Client, chatID int64) (int64, error) {
+	channel, err := client.GetSendableChannel(chatID)
+	if err != nil {
+		return 0, err
+	}
+	full, err := client.ChannelsGetFullChannel(channel)
+	if err != nil {
+		return 0, err
+	}
+	id := store.PeerOf(chatID).ID
+	for _, c := range full.Chats {
+		if ch, ok := c.(*telegram.Channel); ok && ch.ID == id && !ch.Broadcast {
+			return 0, errNotBroadcast
+		}
+	}
+	info, ok := full.FullChat.(*telegram.ChannelFull)
+	if !ok || info.LinkedChatID == 0 {
+		return 0, nil
+	}
+	return store.Peer{Type: store.PeerChannel, ID: info.LinkedChatID}.ChatID(), nil
+}
+
+// matchesFilter reports whether m passes f. Link previews don't count as
+// media.
+func matchesFilter(f store.Filter, m *telegram.NewMessage) bool {
+	media := m.IsMedia()
+	if _, preview := m.Media().(*telegram.MessageMediaWebPage); preview {
+		media = false
+	}
+	switch f {
+	case store.FilterText:
+		return !media
+	case store.FilterMedia:
+		return media
+	}
+	return true
+}
+
+// reactionPool returns the emojis a session picks from for kind. Comments
+// use the post pools while their own are empty.
+func reactionPool(st store.Store, premium bool, kind store.Kind) ([]string, error) {
+	if kind == store.KindComment {
+		emojis, err := st.GetCommentEmojis(premium)
+		if err != nil || len(emojis) > 0 {
+			return emojis, err
+		}
+	}
+	if premium {
+		return st.GetPremEmojis()
+	}
+	return st.GetNpremEmojis()
+}
+
+func registerCommentCommands(client *telegram.Client, st store.Store, sessions []*Session, a *access, au *auditor) {
+	client.On("cmd:comments", a.requireChat("Usage: /comments &lt;chat_id&gt; on|off", func(m *telegram.NewMessage, chatID int64) error {
+		args := strings.Fields(strings.ToLower(m.Args()))
+		if len(args) != 2 || (args[1] != "on" && args[1] != "off") {
+			_, _ = m.Reply("Usage: /comments &lt;chat_id&gt; on|off")
+			return nil
+		}
+		on := args[1] == "on"
+		before := commentsState(st, chatID)
+		if err := st.SetChatComments(chatID, on); err != nil {
+			if errors.Is(err, store.ErrChatNotFound) {
+				_, _ = m.Reply(fmt.Sprintf("❌ Chat %d is not in the auto-react list.", chatID))
+				return nil
+			}
+			_, _ = m.Reply("❌ Failed to change comments: " + err.Error())
+			return err
+		}
+		if !on {
+			au.recordMsg(m, "comments", before, commentsState(st, chatID))
+			_, _ = m.Reply(fmt.Sprintf("✅ Chat %d: comments off.", chatID))
+			return nil
+		}
+		discussion, err := lookUpDiscussion(connectedSessions(sessions), chatID)
+		if errors.Is(err, errNotBroadcast) {
+			// Comments were switched on above; switch them off again, which
+			// also turns off comments that were on before this command.
+			if err := st.SetChatComments(chatID, false); err != nil {
+				_, _ = m.Reply(fmt.Sprintf("❌ Chat %d is not a broadcast channel, and turning comments back off failed: %s", chatID, html.EscapeString(err.Error())))
+				return err
+			}
+			au.recordMsg(m, "comments", before, commentsState(st, chatID))
+			_, _ = m.Reply(fmt.Sprintf("❌ Chat %d is not a broadcast channel, so it has no comments.", chatID))
+			return nil
+		}
+		if discussion != 0 {
+			if err := st.SetChatDiscussion(chatID, discussion); err != nil {
+				_, _ = m.Reply("❌ Failed to save the discussion group: " + err.Error())
+				return err
+			}
+		}
+		au.recordMsg(m, "comments", before, commentsState(st, chatID))
+		switch {
+		case discussion != 0:
+			_, _ = m.Reply(fmt.Sprintf("✅ Chat %d: comments on, in discussion group <code>%d</code>. Sessions must be members of it to react.", chatID, discussion))
+		case err != nil:
+			_, _ = m.Reply(fmt.Sprintf("✅ Chat %d: comments on. The discussion group couldn't be looked up yet (%s); sessions will find it with the next post.", chatID, html.EscapeString(err.Error())))
+		default:
+			_, _ = m.Reply(fmt.Sprintf("✅ Chat %d: comments on, but the channel has no discussion group yet. Sessions will find it with the next post after one is linked.", chatID))
+		}
+		return nil
+	}))
+
+	const ruleUsage = "Usage: /rule &lt;chat_id&gt; post|comment &lt;1-100&gt;[%] [all|text|media]\nReacts to that share of posts or comments, optionally only to text or media."
+	client.On("cmd:rule", a.requireChat(ruleUsage, func(m *telegram.NewMessage, chatID int64) error {
+		args := strings.Fields(strings.ToLower(m.Args()))
+		if len(args) < 3 || len(args) > 4 {
+			_, _ = m.Reply(ruleUsage)
+			return nil
+		}
+		kind := store.Kind(args[1])
+		if kind != store.KindPost && kind != store.KindComment {
+			_, _ = m.Reply(ruleUsage)
+			return nil
+		}
+		chat, ok, err := st.GetChat(chatID)
+		if err != nil {
+			_, _ = m.Reply("❌ Error: " + err.Error())
+			return err
+		}
+		if !ok {
+			_, _ = m.Reply(fmt.Sprintf("❌ Chat %d is not in the auto-react list.", chatID))
+			return nil
+		}
+		rule := chat.Rule(kind)
+		if rule.Probability, err = strconv.Atoi(strings.TrimSuffix(args[2], "%")); err != nil {
+			_, _ = m.Reply("❌ Invalid probability.\n" + ruleUsage)
+			return nil
+		}
+		if len(args) == 4 {
+			rule.Filter = store.Filter(args[3])
+		}
+		if err := rule.Validate(); err != nil {
+			_, _ = m.Reply("❌ " + html.EscapeString(err.Error()) + "\n" + ruleUsage)
+			return nil
+		}
+		before := chat.Rule(kind).String()
+		if err := st.SetChatRule(chatID, kind, rule); err != nil {
+			_, _ = m.Reply("❌ Failed to set rule: " + err.Error())
+			return err
+		}
+		au.recordMsg(m, "rule", fmt.Sprintf("%s: %s", kind, before), fmt.Sprintf("%s: %s", kind, rule))
+		_, _ = m.Reply(fmt.Sprintf("✅ Chat %d %ss: %s", chatID, kind, html.EscapeString(rule.String())))
+		return nil
+	}))
+
+	client.On("cmd:addcommentemoji", a.require(store.RoleAdmin, func(m *telegram.NewMessage) error {
+		args := strings.Fields(m.Args())
+		if len(args) < 2 || (args[0] != "prem" && args[0] != "nprem") {
+			_, _ = m.Reply("Usage: /addcommentemoji prem|nprem &lt;emoji…&gt;\nComments use the post pools while their own pool is empty.")
+			return nil
+		}
+		premium := args[0] == "prem"
+		list := func() ([]string, error) { return st.GetCommentEmojis(premium) }
+		before := poolState(list)
+		var added, invalid []string
+		for _, emoji := range args[1:] {
+			if !IsValidReaction(emoji) {
+				invalid = append(invalid, emoji)
+				continue
+			}
+			if err := st.AddCommentEmoji(premium, emoji); err != nil {
+				_, _ = m.Reply("❌ Failed to add comment emoji: " + err.Error())
+				return err
+			}
+			added = append(added, emoji)
+		}
+		if len(added) > 0 {
+			au.recordMsg(m, "addcommentemoji", before, poolState(list))
+		}
+		var parts []string
+		if len(added) > 0 {
+			parts = append(parts, "✅ "+poolTitles["c"+args[0]]+" emoji(s) added: "+strings.Join(added, " "))
+		}
+		if len(invalid) > 0 {
+			parts = append(parts, "❌ Invalid reaction emoji(s): "+strings.Join(invalid, " ")+"\nUse /validreactions to see valid options.")
+		}
+		_, _ = m.Reply(strings.Join(parts, "\n"))
+		return nil
+	}))
+}
+
+// lookUpDiscussion asks the connected sessions in turn until one can read
+// the channel.
+func lookUpDiscussion(sessions []*Session, chatID int64) (int64, error) {
+	err := errors.New("no session is connected")
+	for _, sess := range sessions {
+		client := sess.Client()
+		if client == nil {
+			continue
+		}
+		var id int64
+		if id, err = linkedChat(client, chatID); err == nil || errors.Is(err, errNotBroadcast) {
+			return id, err
+		}
+	}
+	return 0, err
+}
+
+// commentsState describes a chat's comment setting for the audit log.
+func commentsState(st store.Store, chatID int64) string {
+	chat, ok, err := st.GetChat(chatID)
+	switch {
+	case err != nil:
+		return "error: " + err.Error()
+	case !ok:
+		return "not monitored"
+	case !chat.Comments:
+		return "off"
+	case chat.DiscussionID == 0:
+		return "on"
+	}
+	return fmt.Sprintf("on (%d)", chat.DiscussionID)
+}
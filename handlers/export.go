@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+// maxImportSize caps the document /import downloads; real snapshots are a
+// few kilobytes.
+const maxImportSize = 1 << 20
+
+// maxDiffLines keeps the /import preview inside one Telegram message.
+const maxDiffLines = 40
+
+func registerExportCommands(client *telegram.Client, st store.Store, a *access, au *auditor) {
+	client.On("cmd:export", a.require(store.RoleAdmin, func(m *telegram.NewMessage) error {
+		format := strings.ToLower(strings.TrimSpace(m.Args()))
+		if format == "" {
+			format = "json"
+		}
+		snap, err := st.Export()
+		if err != nil {
+			_, _ = m.Reply("❌ Export failed: " + err.Error())
+			return err
+		}
+		data, err := snap.Marshal(format)
+		if err != nil {
+			_, _ = m.Reply("Usage: /export [json|yaml]")
+			return nil
+		}
+		name := fmt.Sprintf("reactionbot-%s.%s", snap.ExportedAt.Format("20060102-150405"), format)
+		_, err = m.ReplyMedia(data, &telegram.MediaOptions{
+			FileName:      name,
+			ForceDocument: true,
+			Caption:       fmt.Sprintf("📦 %d chat(s), %d+%d emoji(s), %d user(s)", len(snap.Chats), len(snap.PremEmojis), len(snap.NpremEmojis), len(snap.Users)),
+		})
+		if err != nil {
+			_, _ = m.Reply("❌ Failed to send export: " + err.Error())
+		}
+		return err
+	}))
+
+	client.On("cmd:import", a.require(store.RoleOwner, func(m *telegram.NewMessage) error {
+		const usage = "Usage: reply to an exported file with /import merge|replace [confirm]\n\n" +
+			"Without <code>confirm</code> only a preview of the changes is shown."
+		args := strings.Fields(strings.ToLower(m.Args()))
+		if len(args) == 0 || len(args) > 2 || (len(args) == 2 && args[1] != "confirm") || !m.IsReply() {
+			_, _ = m.Reply(usage)
+			return nil
+		}
+		mode, err := store.ParseImportMode(args[0])
+		if err != nil {
+			_, _ = m.Reply("❌ " + err.Error())
+			return nil
+		}
+		apply := len(args) == 2
+
+		reply, err := m.GetReplyMessage()
+		if err != nil {
+			_, _ = m.Reply("❌ Failed to fetch the replied-to message: " + err.Error())
+			return err
+		}
+		doc := reply.Document()
+		if doc == nil {
+			_, _ = m.Reply(usage)
+			return nil
+		}
+		if doc.Size > maxImportSize {
+			_, _ = m.Reply(fmt.Sprintf("❌ File is too large (%d bytes, limit %d).", doc.Size, maxImportSize))
+			return nil
+		}
+		var buf bytes.Buffer
+		if _, err := reply.Download(&telegram.DownloadOptions{Buffer: &buf}); err != nil {
+			_, _ = m.Reply("❌ Failed to download file: " + err.Error())
+			return err
+		}
+		snap, err := store.ParseSnapshot(buf.Bytes())
+		if err != nil {
+			_, _ = m.Reply("❌ " + html.EscapeString(err.Error()))
+			return nil
+		}
+
+		changes, err := st.Import(snap, mode, !apply)
+		if err != nil {
+			_, _ = m.Reply("❌ Import failed, nothing was changed: " + html.EscapeString(err.Error()))
+			return err
+		}
+		if len(changes) == 0 {
+			_, _ = m.Reply("ℹ️ The database already matches this file.")
+			return nil
+		}
+		if apply {
+			au.recordMsg(m, "import", "", fmt.Sprintf("%d change(s) from snapshot of %s", len(changes), snap.ExportedAt.Format(time.RFC3339)))
+		}
+		header := fmt.Sprintf("🔍 <b>Preview of /import %s</b> (%d change(s), nothing applied yet)", args[0], len(changes))
+		footer := fmt.Sprintf("\n\nReply to the file again with <code>/import %s confirm</code> to apply.", args[0])
+		if apply {
+			header = fmt.Sprintf("✅ <b>Imported</b> (%d change(s))", len(changes))
+			footer = ""
+		}
+		_, _ = m.Reply(header + "\n<pre>" + html.EscapeString(formatChanges(changes)) + "</pre>" + footer)
+		return nil
+	}))
+}
+
+func formatChanges(changes []string) string {
+	if len(changes) <= maxDiffLines {
+		return strings.Join(changes, "\n")
+	}
+	return strings.Join(changes[:maxDiffLines], "\n") + fmt.Sprintf("\n… and %d more", len(changes)-maxDiffLines)
+}
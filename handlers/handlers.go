@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"html"
-	"log"
+	"log/slog"
 	"math/rand/v2"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/amarnathcjd/gogram/telegram"
 	"github.com/sandeep97217890-droid/ReactionBot/store"
@@ -15,44 +18,217 @@ import (
 
 const maxPremiumReactions = 3
 
-type Session struct {
-	Client    *telegram.Client
-	IsPremium bool
+type Reactor struct {
+	ctx      context.Context
+	st       store.Store
+	sessions []*Session
+	seen     sync.Map
+	health   *StorageHealth
+	members  membership
+	queue    *ReactionQueue
+	lookups  sync.Map // seenKey without msgID → time of the last discussion lookup
+	albums   albumBuffer
+
+	mu      sync.RWMutex
+	events  Events
+	pending map[string][]store.PendingReaction // by session
+}
+
+// Register wires auto-reactions into every connected session. Each session
+// reacts only in the chats of its own tenant, together with the other
+// sessions of that tenant that are in the chat, or only the ones assigned to
+// it. Reactions are sent by a worker pool sized by pool, so a message
+// doesn't hold up the update handler. Once ctx is done new messages are
+// ignored; Shutdown then finishes or saves the queued reactions, and the
+// ones saved by the previous run are queued again as their sessions attach.
+// The returned Reactor's Attach must be called again whenever a session
+// gets a new client.
+func Register(ctx context.Context, sessions []*Session, st store.Store, pool PoolConfig) *Reactor {
+	r := &Reactor{ctx: ctx, st: st, sessions: sessions, health: &StorageHealth{}}
+	r.queue = newReactionQueue(pool, r.sendReaction)
+	r.health.record("session_tags", loadSessionTags(st, sessions))
+	r.health.record("session_usage", loadUsage(st, sessions))
+	r.health.record("take_pending_reactions", r.loadPending())
+	for _, sess := range sessions {
+		if sess.Client() != nil {
+			r.Attach(sess)
+		}
+	}
+	return r
+}
+
+// Health reports the store errors seen on the reaction path.
+func (r *Reactor) Health() *StorageHealth {
+	return r.health
+}
+
+// Queue reports the backlog of reactions waiting for a worker.
+func (r *Reactor) Queue() *ReactionQueue {
+	return r.queue
 }
 
-func Register(sessions []Session, st *store.Store) {
-	if len(sessions) == 0 {
+// Shutdown stops queueing reactions and waits up to grace for the queued
+// ones to be sent. Those still queued then are saved for the next start,
+// as are the ones saved by the previous run for sessions that never
+// attached. Sessions must stay connected until it returns.
+func (r *Reactor) Shutdown(grace time.Duration) {
+	left := r.queue.Shutdown(grace)
+	r.savePending(left, r.unreplayed())
+}
+
+func (r *Reactor) Attach(sess *Session) {
+	client := sess.Client()
+	if client == nil {
 		return
 	}
-	var seen sync.Map
-	for _, sess := range sessions {
-		sess := sess
-		sess.Client.On(telegram.OnNewMessage, func(m *telegram.NewMessage) error {
-			if !st.IsEnabled() || !st.HasChat(m.ChatID()) {
-				return nil
-			}
-			chatID := m.ChannelID()
-			msgID := m.ID
-			key := [2]int64{chatID, int64(msgID)}
-			if _, loaded := seen.LoadOrStore(key, struct{}{}); loaded {
-				return nil
-			}
-			fmt.Println("Received message in chat", m.ChatID(), "– reacting with all sessions")
-			for _, s := range sessions {
-				sendReaction(s, st, chatID, msgID)
-			}
-			return nil
+	client.On(telegram.OnNewMessage, func(m *telegram.NewMessage) error {
+		return r.onMessage(sess, m)
+	})
+	client.On(telegram.OnAction, r.onAction)
+	r.resolveMembership(sess)
+	r.replay(sess)
+}
+
+// seenKey deduplicates a message per tenant: every session of the tenant sees
+// it, but only the first to do so reacts on behalf of all of them.
+type seenKey struct {
+	tenant string
+	chatID int64
+	msgID  int32
+}
+
+func (r *Reactor) onMessage(from *Session, m *telegram.NewMessage) error {
+	if r.ctx.Err() != nil {
+		return nil
+	}
+	tenant := from.Tenant
+	st := r.st.ForTenant(tenant)
+	enabled, err := st.IsEnabled()
+	if !r.health.record("is_enabled", err, "tenant", tenant) || !enabled {
+		return nil
+	}
+	// ChannelID is the canonical chat ID for every peer type, so the same
+	// value is matched against the store and handed to SendReaction.
+	chatID := m.ChannelID()
+	chat, kind, ok, err := r.target(st, chatID)
+	if !r.health.record("get_chat", err, "tenant", tenant, "chat_id", chatID) || !ok || !chat.Enabled {
+		return nil
+	}
+	switch {
+	case kind == store.KindComment && !m.IsReply():
+		// The group's copy of the post, or talk outside the comment threads.
+		return nil
+	case kind == store.KindPost && chat.Comments && chat.DiscussionID == 0:
+		r.findDiscussion(from, st, chat.ID)
+	}
+	r.members.joined(from.ID, chatID)
+	msgID := m.ID
+	if _, loaded := r.seen.LoadOrStore(seenKey{tenant, chatID, msgID}, struct{}{}); loaded {
+		return nil
+	}
+	if grouped := m.Message.GroupedID; grouped != 0 && chat.Albums != store.AlbumAll {
+		r.albums.add(albumKey{tenant, chatID, grouped}, m, func(items []*telegram.NewMessage) {
+			r.react(st, chat, kind, pickAlbumItem(chat.Albums, items))
 		})
+		return nil
+	}
+	r.react(st, chat, kind, m)
+	return nil
+}
+
+// react queues reactions to m by the sessions picked for chat, if m passes
+// the chat's rule for kind, and returns how many it queued.
+func (r *Reactor) react(st store.Store, chat store.Chat, kind store.Kind, m *telegram.NewMessage) int {
+	if rule := chat.Rule(kind); !matchesFilter(rule.Filter, m) || rand.IntN(100) >= rule.Probability {
+		return 0
+	}
+	tenant, chatID, msgID := st.Tenant(), m.ChannelID(), m.ID
+	now := time.Now()
+	sessions, err := r.reactors(st, chat, chatID, now)
+	if !r.health.record("chat_sessions", err, "tenant", tenant, "chat_id", chatID) {
+		return 0
+	}
+	slog.Debug("Reacting to message", "tenant", tenant, "chat_id", chatID, "msg_id", msgID, "kind", kind, "sessions", len(sessions))
+	queued := 0
+	for i, s := range sessions {
+		if !r.queue.submit(reactionJob{st: st, sess: s, chatID: chatID, msgID: msgID, kind: kind, queued: now, reserved: now}) {
+			for _, rest := range sessions[i:] {
+				rest.release(now)
+			}
+			break
+		}
+		queued++
+	}
+	return queued
+}
+
+// reactors picks the sessions that react in target, which is chat or its
+// discussion group: the sessions assigned to chat, or every session of the
+// tenant when none are, minus those known not to be in target and those out
+// of quota. When the chat asks for a fixed number of reactors, that many are
+// drawn at random from the rest, so an exhausted session is replaced by
+// another eligible one. Each session returned holds one unit of its quota,
+// reserved at now.
+func (r *Reactor) reactors(st store.Store, chat store.Chat, target int64, now time.Time) ([]*Session, error) {
+	assigned, err := st.ChatSessions(chat.ID)
+	if err != nil {
+		return nil, err
+	}
+	want := chat.Reactors
+	var candidates []*Session
+	for _, sess := range sessionsOf(r.sessions, st.Tenant()) {
+		if len(assigned) > 0 && !slices.ContainsFunc(assigned, sess.Matches) {
+			continue
+		}
+		if !r.members.excluded(sess.ID, target) {
+			candidates = append(candidates, sess)
+		}
+	}
+	if want > 0 {
+		rand.Shuffle(len(candidates), func(i, j int) { candidates[i], candidates[j] = candidates[j], candidates[i] })
+	}
+	var out []*Session
+	for _, sess := range candidates {
+		if want > 0 && len(out) == want {
+			break
+		}
+		if sess.reserve(now) {
+			out = append(out, sess)
+		}
+	}
+	return out, nil
+}
+
+func sessionsOf(sessions []*Session, tenant string) []*Session {
+	var out []*Session
+	for _, sess := range sessions {
+		if sess.Tenant == tenant {
+			out = append(out, sess)
+		}
 	}
+	return out
 }
 
-func sendReaction(sess Session, st *store.Store, chatID int64, msgID int32) {
+func (r *Reactor) sendReaction(ctx context.Context, job reactionJob) {
+	st, sess, chatID, msgID := job.st, job.sess, job.chatID, job.msgID
+	// held is the quota unit reserved when the job was queued, given back
+	// if the job ends before using it.
+	held := job.reserved
+	defer func() {
+		if !held.IsZero() {
+			sess.release(held)
+		}
+	}()
+	client := sess.Client()
+	if client == nil {
+		return
+	}
+	emojis, err := reactionPool(st, sess.IsPremium, job.kind)
+	if !r.health.record("get_emojis", err, "premium", sess.IsPremium, "kind", job.kind) || len(emojis) == 0 {
+		return
+	}
 	var reaction []string
 	if sess.IsPremium {
-		emojis, err := st.GetPremEmojis()
-		if err != nil || len(emojis) == 0 {
-			return
-		}
 		rand.Shuffle(len(emojis), func(i, j int) { emojis[i], emojis[j] = emojis[j], emojis[i] })
 		count := maxPremiumReactions
 		if len(emojis) < count {
@@ -60,16 +236,32 @@ func sendReaction(sess Session, st *store.Store, chatID int64, msgID int32) {
 		}
 		reaction = emojis[:count]
 	} else {
-		emojis, err := st.GetNpremEmojis()
-		if err != nil || len(emojis) == 0 {
-			return
-		}
 		reaction = []string{emojis[rand.IntN(len(emojis))]}
 	}
 	for _, emoji := range reaction {
-		if err := sess.Client.SendReaction(chatID, msgID, []string{emoji}, true); err != nil {
-			log.Printf("SendReaction failed (isPremium=%v, chatID=%d, msgID=%d, emoji=%v): %v", sess.IsPremium, chatID, msgID, emoji, err)
+		if ctx.Err() != nil {
+			return
+		}
+		at := held
+		held = time.Time{}
+		if at.IsZero() {
+			if at = time.Now(); !sess.reserve(at) {
+				slog.Debug("Session quota reached", "session", sess.ID, "chat_id", chatID, "msg_id", msgID)
+				return
+			}
+		}
+		if err := client.SendReaction(chatID, msgID, []string{emoji}, true); err != nil {
+			sess.release(at)
+			if isNotMember(err) {
+				slog.Info("Session is not in chat, leaving it out", "session", sess.ID, "chat_id", chatID, "err", err)
+				r.members.left(sess.ID, chatID)
+				return
+			}
+			slog.Warn("SendReaction failed", "session", sess.ID, "premium", sess.IsPremium, "chat_id", chatID, "msg_id", msgID, "emoji", emoji, "err", err)
+			sess.reportError(err)
+			continue
 		}
+		r.health.record("add_session_usage", st.AddSessionUsage(sess.ID, at, 1), "session", sess.ID)
 	}
 }
 
@@ -77,19 +269,84 @@ const helpText = `🤖 <b>ReactionBot Commands</b>
 
 /start - Show welcome message
 /help - Show this help message
+/panel - Open the inline control panel
 /react on|off - Enable or disable auto-reactions
-/joinchat &lt;link&gt; - Join a chat via private (<code>+Hash</code>) or public (<code>@username</code>) invite link
+/joinchat &lt;link&gt; [session|#tag…] - Join a chat via private (<code>+Hash</code>) or public (<code>@username</code>) invite link
 /addchat &lt;chat_id&gt; - Add a chat to the auto-react list
 /removechat &lt;chat_id&gt; - Remove a chat from the auto-react list
+/pausechat &lt;chat_id&gt; - Pause auto-reactions in one chat
+/resumechat &lt;chat_id&gt; - Resume auto-reactions in one chat
+/assign &lt;chat_id&gt; &lt;session|#tag…&gt; - Only let these sessions react in a chat
+/unassign &lt;chat_id&gt; [session|#tag…] - Unassign sessions; with none listed, all member sessions react again
+/tag &lt;session|#tag|all&gt; &lt;tag…&gt; - Tag sessions
+/untag &lt;session|#tag|all&gt; [tag…] - Remove some or all tags from sessions
+/reactors &lt;chat_id&gt; &lt;n|all&gt; - Let only n eligible sessions react to each message in a chat
+/comments &lt;chat_id&gt; on|off - Also react to comments in a channel's discussion group
+/rule &lt;chat_id&gt; post|comment &lt;1-100&gt; [all|text|media] - React to that share of posts or comments, optionally only text or media
+/addcommentemoji prem|nprem &lt;emoji…&gt; - Give comments their own emoji pool
+/albums &lt;chat_id&gt; first|caption|all - Choose which items of a media album get reactions
+/backfill &lt;chat_id&gt; &lt;count|since&gt; - React to recent history, e.g. 200 or 12h
+/backfill cancel - Stop the running backfill
 /listchats - List all monitored chats
 /addpremoji &lt;emoji…&gt; - Add one or more premium reaction emojis (space-separated)
 /addnpemoji &lt;emoji…&gt; - Add one or more non-premium reaction emojis (space-separated)
 /listemojis - List all configured emojis
 /validreactions - Show all valid Telegram reaction emojis
-/status - Show current bot status`
+/sessions - Show userbot session health and remaining quota, grouped by tag
+/status - Show current bot status
+/grant &lt;user_id&gt; owner|admin|viewer - Give a user a role (owner)
+/revoke &lt;user_id&gt; - Remove a user's role and the chats they manage (owner)
+/users - List users and roles (owner)
+/addmanager &lt;user_id&gt; &lt;chat_id…&gt; - Delegate chats to a manager (owner)
+/removemanager &lt;user_id&gt; [chat_id…] - Take chats away from a manager (owner)
+/managers - List chat managers
+/audit [page] - Page through the audit log of changes
+/export [json|yaml] - Download the full configuration as a file
+/import merge|replace [confirm] - Preview or apply a replied-to export (owner)
+/backup - Receive a database backup in private (owner of the default tenant)`
+
+// Tenant is one team served by the bot.
+type Tenant struct {
+	ID       string
+	OwnerIDs []int64
+	// AuditChatID, when set, receives a copy of every audit log entry.
+	AuditChatID int64
+}
+
+// BotConfig holds the bot options that come from the configuration file.
+type BotConfig struct {
+	Tenants []Tenant
+	// Storage is shared with the Reactor so /status reports errors from the
+	// reaction path too. A fresh one is used when nil.
+	Storage *StorageHealth
+	// Queue, when set, adds the reaction backlog to /status.
+	Queue *ReactionQueue
+	// Reactor, when set, enables /backfill and has the sessions' chats
+	// resolved again after /addchat and /joinchat.
+	Reactor *Reactor
+	// ChatDefaults are the settings /addchat gives new chats; the zero
+	// value means store.DefaultChatDefaults.
+	ChatDefaults store.ChatDefaults
+}
 
-func RegisterBot(client *telegram.Client, st *store.Store, ownerIDs []int64, userClients []*telegram.Client) {
-	f := telegram.FromUser(ownerIDs...)
+// RegisterBot registers the bot commands once per tenant. Every set only
+// answers users holding a role in its tenant, so the tenant of a command is
+// that of its sender and each team only ever sees its own data. The returned
+// Events reach each tenant's owners through this bot.
+func RegisterBot(client *telegram.Client, st store.Store, sessions []*Session, cfg BotConfig) Events {
+	health := cfg.Storage
+	if health == nil {
+		health = &StorageHealth{}
+	}
+	if cfg.ChatDefaults == (store.ChatDefaults{}) {
+		cfg.ChatDefaults = store.DefaultChatDefaults
+	}
+	owners := make(map[int64]string)
+	for _, t := range cfg.Tenants {
+		for _, id := range t.OwnerIDs {
+			owners[id] = t.ID
+		}
+	}
 
 	client.On("cmd:start", func(m *telegram.NewMessage) error {
 		_, _ = m.Reply("👋 Welcome to <b>ReactionBot</b>!\n\nI automatically react to messages in configured chats.\nSend /help to see all available commands.")
@@ -101,11 +358,35 @@ func RegisterBot(client *telegram.Client, st *store.Store, ownerIDs []int64, use
 		return nil
 	})
 
-	client.On("cmd:react", func(m *telegram.NewMessage) error {
+	events := &botEvents{client: client, auditors: make(map[string]*auditor), owners: make(map[string][]int64)}
+	for _, t := range cfg.Tenants {
+		tst := st.ForTenant(t.ID)
+		a := newAccess(tst, t.OwnerIDs, owners)
+		au := &auditor{st: tst, client: client, chatID: t.AuditChatID}
+		events.auditors[t.ID] = au
+		events.owners[t.ID] = t.OwnerIDs
+		registerTenant(client, tst, sessionsOf(sessions, t.ID), a, au, health, cfg)
+	}
+	return events
+}
+
+func registerTenant(client *telegram.Client, st store.Store, sessions []*Session, a *access, au *auditor, health *StorageHealth, cfg BotConfig) {
+	reactor := cfg.Reactor
+
+	client.On("cmd:react", a.require(store.RoleAdmin, func(m *telegram.NewMessage) error {
 		arg := strings.ToLower(strings.TrimSpace(m.Args()))
+		if arg != "on" && arg != "off" {
+			_, _ = m.Reply("Usage: /react on|off")
+			return nil
+		}
+		enabled, err := st.IsEnabled()
+		if !health.record("is_enabled", err) {
+			_, _ = m.Reply("❌ Error: " + err.Error())
+			return err
+		}
 		switch arg {
 		case "on":
-			if st.IsEnabled() {
+			if enabled {
 				_, _ = m.Reply("ℹ️ Auto-reactions are already enabled.")
 				return nil
 			}
@@ -113,9 +394,10 @@ func RegisterBot(client *telegram.Client, st *store.Store, ownerIDs []int64, use
 				_, _ = m.Reply("❌ Failed to enable: " + err.Error())
 				return err
 			}
+			au.recordMsg(m, "react", onOff(false), onOff(true))
 			_, _ = m.Reply("✅ Auto-reactions enabled.")
 		case "off":
-			if !st.IsEnabled() {
+			if !enabled {
 				_, _ = m.Reply("ℹ️ Auto-reactions are already disabled.")
 				return nil
 			}
@@ -123,46 +405,64 @@ func RegisterBot(client *telegram.Client, st *store.Store, ownerIDs []int64, use
 				_, _ = m.Reply("❌ Failed to disable: " + err.Error())
 				return err
 			}
+			au.recordMsg(m, "react", onOff(true), onOff(false))
 			_, _ = m.Reply("🚫 Auto-reactions disabled.")
-		default:
-			_, _ = m.Reply("Usage: /react on|off")
 		}
 		return nil
-	}, f)
+	}))
 
-	client.On("cmd:joinchat", func(m *telegram.NewMessage) error {
-		arg := strings.TrimSpace(m.Args())
-		if arg == "" {
-			_, _ = m.Reply("Usage: /joinchat &lt;invite_link&gt;\n\nSupports:\n• Private: <code>+AbCdEfGh</code> or <code>https://t.me/+AbCdEfGh</code>\n• Public: <code>@username</code> or <code>https://t.me/username</code>")
+	client.On("cmd:joinchat", a.require(store.RoleAdmin, func(m *telegram.NewMessage) error {
+		args := strings.Fields(m.Args())
+		if len(args) == 0 {
+			_, _ = m.Reply("Usage: /joinchat &lt;invite_link&gt; [session|#tag…]\n\nWithout sessions, every session joins.\n\nSupports:\n• Private: <code>+AbCdEfGh</code> or <code>https://t.me/+AbCdEfGh</code>\n• Public: <code>@username</code> or <code>https://t.me/username</code>")
 			return nil
 		}
-		if len(userClients) == 0 {
+		if len(sessions) == 0 {
 			_, _ = m.Reply("❌ No userbot sessions configured. Add <code>PREM_SESSIONS</code> or <code>NPREM_SESSIONS</code>.")
 			return nil
 		}
 
-		link := arg
+		link := args[0]
 		if strings.HasPrefix(link, "+") {
 			link = "https://t.me/" + link
 		}
 
+		joining := sessions
+		if len(args) > 1 {
+			sels := make([]string, len(args)-1)
+			for i, arg := range args[1:] {
+				sels[i] = normalizeSelector(arg)
+			}
+			var unknown []string
+			if joining, unknown = selectSessions(sessions, sels); len(unknown) > 0 {
+				_, _ = m.Reply("❌ Unknown session(s): " + html.EscapeString(strings.Join(unknown, ", ")) + "\nSee /sessions.")
+				return nil
+			}
+		}
+		live := connectedSessions(joining)
+		if len(live) == 0 {
+			_, _ = m.Reply("❌ No userbot session is connected right now. Check /sessions.")
+			return nil
+		}
+
 		var lastErr error
 		joined := 0
 		var joinedChatID int64
 		chatIDResolved := false
-		for i, uc := range userClients {
-			if ch, err := uc.JoinChannel(link); err != nil {
-				log.Printf("JoinChannel failed for session %d/%d (link=%s): %v", i+1, len(userClients), link, err)
+		for _, sess := range live {
+			if ch, err := sess.Client().JoinChannel(link); err != nil {
+				slog.Warn("JoinChannel failed", "session", sess.ID, "link", link, "err", err)
 				lastErr = err
 			} else {
 				if !chatIDResolved && ch != nil {
-					joinedChatID = ch.ID
+					joinedChatID = store.Peer{Type: store.PeerChannel, ID: ch.ID}.ChatID()
 					chatIDResolved = true
 				}
 				joined++
 			}
 		}
 
+		au.recordMsg(m, "joinchat", "", fmt.Sprintf("joined by %d/%d sessions", joined, len(live)))
 		if joined == 0 {
 			errMsg := "unknown error"
 			if lastErr != nil {
@@ -171,65 +471,98 @@ func RegisterBot(client *telegram.Client, st *store.Store, ownerIDs []int64, use
 			_, _ = m.Reply("❌ Failed to join chat: " + html.EscapeString(errMsg))
 			return nil
 		}
+		if reactor != nil {
+			reactor.RefreshMembership(st.Tenant())
+		}
 
 		if chatIDResolved {
 			_, _ = m.Reply(fmt.Sprintf(
 				"✅ Joined chat via invite link (%d/%d sessions succeeded).\nUse /addchat <code>%d</code> to start monitoring.",
-				joined, len(userClients), joinedChatID,
+				joined, len(live), joinedChatID,
 			))
 		} else {
 			_, _ = m.Reply(fmt.Sprintf(
 				"✅ Joined chat via invite link (%d/%d sessions succeeded).\nUse /addchat &lt;chat_id&gt; to start monitoring.",
-				joined, len(userClients),
+				joined, len(live),
 			))
 		}
 		return nil
-	}, f)
+	}))
 
-	client.On("cmd:addchat", func(m *telegram.NewMessage) error {
+	client.On("cmd:addchat", a.require(store.RoleAdmin, func(m *telegram.NewMessage) error {
 		arg := strings.TrimSpace(m.Args())
 		if arg == "" {
 			_, _ = m.Reply("Usage: /addchat <chat_id>")
 			return nil
 		}
-		chatID, err := strconv.ParseInt(arg, 10, 64)
+		chatID, err := store.ParseChatID(arg)
 		if err != nil {
-			_, _ = m.Reply("❌ Invalid chat ID: must be a number.")
+			_, _ = m.Reply("❌ Invalid chat ID: " + err.Error())
 			return nil
 		}
-		if err := st.AddChat(chatID); err != nil {
+		before := chatState(st, chatID)
+		if err := st.AddChat(chatID, cfg.ChatDefaults); err != nil {
 			_, _ = m.Reply("❌ Failed to add chat: " + err.Error())
 			return err
 		}
+		au.recordMsg(m, "addchat", before, chatState(st, chatID))
+		if reactor != nil {
+			reactor.RefreshMembership(st.Tenant())
+		}
 		_, _ = m.Reply(fmt.Sprintf("✅ Chat %d added to auto-react list.", chatID))
 		return nil
-	}, f)
+	}))
 
-	client.On("cmd:removechat", func(m *telegram.NewMessage) error {
+	client.On("cmd:removechat", a.require(store.RoleAdmin, func(m *telegram.NewMessage) error {
 		arg := strings.TrimSpace(m.Args())
 		if arg == "" {
 			_, _ = m.Reply("Usage: /removechat <chat_id>")
 			return nil
 		}
-		chatID, err := strconv.ParseInt(arg, 10, 64)
+		chatID, err := store.ParseChatID(arg)
 		if err != nil {
-			_, _ = m.Reply("❌ Invalid chat ID: must be a number.")
+			_, _ = m.Reply("❌ Invalid chat ID: " + err.Error())
 			return nil
 		}
+		before := chatState(st, chatID)
 		if err := st.RemoveChat(chatID); err != nil {
 			_, _ = m.Reply("❌ Failed to remove chat: " + err.Error())
 			return err
 		}
+		au.recordMsg(m, "removechat", before, chatState(st, chatID))
 		_, _ = m.Reply(fmt.Sprintf("✅ Chat %d removed from auto-react list.", chatID))
 		return nil
-	}, f)
+	}))
+
+	client.On("cmd:pausechat", a.requireChat("Usage: /pausechat &lt;chat_id&gt;", func(m *telegram.NewMessage, chatID int64) error {
+		before := chatState(st, chatID)
+		if err := st.SetChatEnabled(chatID, false); err != nil {
+			_, _ = m.Reply("❌ Failed to pause chat: " + err.Error())
+			return nil
+		}
+		au.recordMsg(m, "pausechat", before, chatState(st, chatID))
+		_, _ = m.Reply(fmt.Sprintf("⏸ Auto-reactions paused in chat %d.", chatID))
+		return nil
+	}))
+
+	client.On("cmd:resumechat", a.requireChat("Usage: /resumechat &lt;chat_id&gt;", func(m *telegram.NewMessage, chatID int64) error {
+		before := chatState(st, chatID)
+		if err := st.SetChatEnabled(chatID, true); err != nil {
+			_, _ = m.Reply("❌ Failed to resume chat: " + err.Error())
+			return nil
+		}
+		au.recordMsg(m, "resumechat", before, chatState(st, chatID))
+		_, _ = m.Reply(fmt.Sprintf("▶️ Auto-reactions resumed in chat %d.", chatID))
+		return nil
+	}))
 
-	client.On("cmd:addpremoji", func(m *telegram.NewMessage) error {
+	client.On("cmd:addpremoji", a.require(store.RoleAdmin, func(m *telegram.NewMessage) error {
 		args := strings.Fields(m.Args())
 		if len(args) == 0 {
 			_, _ = m.Reply("Usage: /addpremoji <emoji…>\nEmojis must be space-separated valid Telegram reactions.\nSee /validreactions for the full list.")
 			return nil
 		}
+		before := poolState(st.GetPremEmojis)
 		var added, invalid []string
 		for _, emoji := range args {
 			if !IsValidReaction(emoji) {
@@ -242,6 +575,9 @@ func RegisterBot(client *telegram.Client, st *store.Store, ownerIDs []int64, use
 			}
 			added = append(added, emoji)
 		}
+		if len(added) > 0 {
+			au.recordMsg(m, "addpremoji", before, poolState(st.GetPremEmojis))
+		}
 		var parts []string
 		if len(added) > 0 {
 			parts = append(parts, "✅ Premium emoji(s) added: "+strings.Join(added, " "))
@@ -251,14 +587,15 @@ func RegisterBot(client *telegram.Client, st *store.Store, ownerIDs []int64, use
 		}
 		_, _ = m.Reply(strings.Join(parts, "\n"))
 		return nil
-	}, f)
+	}))
 
-	client.On("cmd:addnpemoji", func(m *telegram.NewMessage) error {
+	client.On("cmd:addnpemoji", a.require(store.RoleAdmin, func(m *telegram.NewMessage) error {
 		args := strings.Fields(m.Args())
 		if len(args) == 0 {
 			_, _ = m.Reply("Usage: /addnpemoji <emoji…>\nEmojis must be space-separated valid Telegram reactions.\nSee /validreactions for the full list.")
 			return nil
 		}
+		before := poolState(st.GetNpremEmojis)
 		var added, invalid []string
 		for _, emoji := range args {
 			if !IsValidReaction(emoji) {
@@ -271,6 +608,9 @@ func RegisterBot(client *telegram.Client, st *store.Store, ownerIDs []int64, use
 			}
 			added = append(added, emoji)
 		}
+		if len(added) > 0 {
+			au.recordMsg(m, "addnpemoji", before, poolState(st.GetNpremEmojis))
+		}
 		var parts []string
 		if len(added) > 0 {
 			parts = append(parts, "✅ Non-premium emoji(s) added: "+strings.Join(added, " "))
@@ -280,27 +620,66 @@ func RegisterBot(client *telegram.Client, st *store.Store, ownerIDs []int64, use
 		}
 		_, _ = m.Reply(strings.Join(parts, "\n"))
 		return nil
-	}, f)
+	}))
 
 	client.On("cmd:listchats", func(m *telegram.NewMessage) error {
-		chats, err := st.GetChats()
+		// Delegated managers without a role only see the chats they manage.
+		var managed []int64
+		if !a.allowed(m.SenderID(), store.RoleViewer) {
+			if managed = a.managedChats(m.SenderID()); len(managed) == 0 {
+				return nil
+			}
+		}
+		chats, err := st.ListChats()
 		if err != nil {
 			_, _ = m.Reply("❌ Error: " + err.Error())
 			return err
 		}
+		if managed != nil {
+			chats = slices.DeleteFunc(chats, func(c store.Chat) bool { return !slices.Contains(managed, c.ID) })
+		}
 		if len(chats) == 0 {
 			_, _ = m.Reply("No chats added yet. Use /addchat <chat_id>.")
 			return nil
 		}
+		assigned, err := st.AllChatSessions()
+		if err != nil {
+			_, _ = m.Reply("❌ Error: " + err.Error())
+			return err
+		}
 		parts := make([]string, len(chats))
-		for i, id := range chats {
-			parts[i] = strconv.FormatInt(id, 10)
+		for i, c := range chats {
+			parts[i] = strconv.FormatInt(c.ID, 10)
+			if !c.Enabled {
+				parts[i] += " (paused)"
+			}
+			if ids := assigned[c.ID]; len(ids) > 0 {
+				parts[i] += " → " + strings.Join(ids, ", ")
+			}
+			if c.Reactors > 0 {
+				parts[i] += fmt.Sprintf(" (%d reactors)", c.Reactors)
+			}
+			if c.Albums != store.DefaultAlbumMode {
+				parts[i] += " · albums: " + string(c.Albums)
+			}
+			if c.Post != store.DefaultRule {
+				parts[i] += " · posts " + c.Post.String()
+			}
+			if c.Comments {
+				parts[i] += " · 💬 comments"
+				if c.DiscussionID != 0 {
+					parts[i] += fmt.Sprintf(" in %d", c.DiscussionID)
+				}
+				if c.Comment != store.DefaultRule {
+					parts[i] += " " + c.Comment.String()
+				}
+			}
 		}
 		_, _ = m.Reply("📋 Monitored chats:\n" + strings.Join(parts, "\n"))
 		return nil
-	}, f)
+	})
 
-	client.On("cmd:listemojis", func(m *telegram.NewMessage) error {
+	client.On("cmd:listemojis", a.require(store.RoleViewer, func(m *telegram.NewMessage) error {
 		prem, err := st.GetPremEmojis()
 		if err != nil {
 			_, _ = m.Reply("❌ Error: " + err.Error())
@@ -311,34 +690,113 @@ func RegisterBot(client *telegram.Client, st *store.Store, ownerIDs []int64, use
 			_, _ = m.Reply("❌ Error: " + err.Error())
 			return err
 		}
-		_, _ = m.Reply(fmt.Sprintf(
+		text := fmt.Sprintf(
 			"⭐ Premium emojis (%d):\n%s\n\n👤 Non-premium emojis (%d):\n%s",
 			len(prem), strings.Join(prem, " "),
 			len(nprem), strings.Join(nprem, " "),
-		))
+		)
+		for _, name := range []string{"cprem", "cnprem"} {
+			emojis, err := st.GetCommentEmojis(name == "cprem")
+			if err != nil {
+				_, _ = m.Reply("❌ Error: " + err.Error())
+				return err
+			}
+			if len(emojis) > 0 {
+				text += fmt.Sprintf("\n\n%s emojis (%d):\n%s", poolTitles[name], len(emojis), strings.Join(emojis, " "))
+			}
+		}
+		_, _ = m.Reply(text)
 		return nil
-	}, f)
+	}))
 
-	client.On("cmd:validreactions", func(m *telegram.NewMessage) error {
+	client.On("cmd:validreactions", a.require(store.RoleViewer, func(m *telegram.NewMessage) error {
 		list := ValidReactionList()
 		_, _ = m.Reply(fmt.Sprintf(
 			"✅ <b>Valid Telegram reaction emojis (%d):</b>\n%s\n\nUse these with /addnpemoji or /addpremoji (space-separated).",
 			len(list), strings.Join(list, " "),
 		))
 		return nil
-	}, f)
+	}))
 
-	client.On("cmd:status", func(m *telegram.NewMessage) error {
-		state := "🚫 OFF"
-		if st.IsEnabled() {
+	client.On("cmd:status", a.require(store.RoleViewer, func(m *telegram.NewMessage) error {
+		state, chatCount := "🚫 OFF", "unknown"
+		if enabled, err := st.IsEnabled(); !health.record("is_enabled", err) {
+			state = "❓ unknown"
+		} else if enabled {
 			state = "✅ ON"
 		}
-		chats, _ := st.GetChats()
-		_, _ = m.Reply(fmt.Sprintf(
-			"🤖 ReactionBot Status\nAuto-react: %s\nAccount: 🤖 Bot\nMonitored chats: %d",
-			state, len(chats),
-		))
+		if chats, err := st.GetChats(); health.record("get_chats", err) {
+			chatCount = strconv.Itoa(len(chats))
+		}
+		text := fmt.Sprintf(
+			"🤖 ReactionBot Status\nAuto-react: %s\nAccount: 🤖 Bot\nMonitored chats: %s\nSessions connected: %d/%d\nStorage: %s",
+			state, chatCount, len(connectedSessions(sessions)), len(sessions), html.EscapeString(health.Status()),
+		)
+		if cfg.Queue != nil {
+			text += "\nReaction queue: " + cfg.Queue.Status()
+		}
+		_, _ = m.Reply(text)
+		return nil
+	}))
+
+	client.On("cmd:sessions", a.require(store.RoleViewer, func(m *telegram.NewMessage) error {
+		if len(sessions) == 0 {
+			_, _ = m.Reply("No userbot sessions configured.")
+			return nil
+		}
+		_, _ = m.Reply(formatSessionGroups(sessions))
 		return nil
-	}, f)
+	}))
+
+	registerPanel(client, st, sessions, a, au)
+	registerUserCommands(client, st, a, au)
+	registerManagerCommands(client, st, a, au)
+	registerAssignCommands(client, st, sessions, a, au)
+	registerTagCommands(client, st, sessions, a, au)
+	registerQuotaCommands(client, st, a, au)
+	registerCommentCommands(client, st, sessions, a, au)
+	registerAlbumCommands(client, st, a, au)
+	registerAuditCommands(client, st, a)
+	registerExportCommands(client, st, a, au)
+	if reactor != nil {
+		registerBackfillCommands(client, st, a, au, reactor)
+	}
+	// A backup holds every tenant, so only the default tenant may take one.
+	if st.Tenant() == store.DefaultTenant {
+		registerBackupCommands(client, st, a, au)
+	}
+}
+
+func connectedSessions(sessions []*Session) []*Session {
+	var out []*Session
+	for _, sess := range sessions {
+		if sess.Client() != nil {
+			out = append(out, sess)
+		}
+	}
+	return out
 }
 
+func formatSession(sess *Session) string {
+	kind := "👤"
+	if sess.IsPremium {
+		kind = "⭐"
+	}
+	line := fmt.Sprintf("%s <code>%s</code>", kind, sess.ID)
+	if userID, name := sess.User(); userID != 0 {
+		line += fmt.Sprintf(" %s (<code>%d</code>)", html.EscapeString(name), userID)
+	}
+	state, reason := sess.State()
+	switch state {
+	case StateConnected:
+		line += " — ✅ connected"
+	case StateRetired:
+		line += " — ⛔ retired: " + html.EscapeString(reason)
+	default:
+		line += " — ♻️ " + string(state)
+		if reason != "" {
+			line += ": " + html.EscapeString(reason)
+		}
+	}
+	return line + formatQuota(sess)
+}
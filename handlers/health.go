@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// degradedWindow is how long after a storage error /status keeps reporting
+// the store as degraded.
+const degradedWindow = 5 * time.Minute
+
+// StorageHealth counts store failures seen while handling updates, so they
+// show up in /status instead of only in the logs.
+type StorageHealth struct {
+	mu     sync.Mutex
+	errors int64
+	last   error
+	lastAt time.Time
+}
+
+// record logs err, if any, and counts it. It reports whether err was nil so
+// callers can bail out in one line.
+func (h *StorageHealth) record(op string, err error, args ...any) bool {
+	if err == nil {
+		return true
+	}
+	slog.Error("Storage error", append([]any{"op", op, "err", err}, args...)...)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.errors++
+	h.last = err
+	h.lastAt = time.Now()
+	return false
+}
+
+// Status is a one-line summary for /status.
+func (h *StorageHealth) Status() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.last == nil || time.Since(h.lastAt) > degradedWindow {
+		if h.errors == 0 {
+			return "✅ OK"
+		}
+		return fmt.Sprintf("✅ OK (%d error(s) since start)", h.errors)
+	}
+	return fmt.Sprintf("⚠️ storage degraded: %d error(s), last %s ago: %v",
+		h.errors, time.Since(h.lastAt).Round(time.Second), h.last)
+}
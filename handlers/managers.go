@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+func registerManagerCommands(client *telegram.Client, st store.Store, a *access, au *auditor) {
+	client.On("cmd:addmanager", a.require(store.RoleOwner, func(m *telegram.NewMessage) error {
+		userID, chatIDs, ok := parseManagerArgs(m.Args())
+		if !ok || len(chatIDs) == 0 {
+			_, _ = m.Reply("Usage: /addmanager &lt;user_id&gt; &lt;chat_id…&gt;")
+			return nil
+		}
+		before := managedState(a, userID)
+		var added, unknown []string
+		for _, chatID := range chatIDs {
+			has, err := st.HasChat(chatID)
+			if err != nil {
+				_, _ = m.Reply("❌ Error: " + err.Error())
+				return err
+			}
+			if !has {
+				unknown = append(unknown, strconv.FormatInt(chatID, 10))
+				continue
+			}
+			if err := st.AddChatManager(chatID, userID); err != nil {
+				_, _ = m.Reply("❌ Failed to add manager: " + err.Error())
+				return err
+			}
+			added = append(added, strconv.FormatInt(chatID, 10))
+		}
+		var parts []string
+		if len(added) > 0 {
+			au.recordMsg(m, "addmanager", before, managedState(a, userID))
+			parts = append(parts, fmt.Sprintf("✅ <code>%d</code> now manages: %s", userID, strings.Join(added, ", ")))
+		}
+		if len(unknown) > 0 {
+			parts = append(parts, "❌ Not in the auto-react list: "+strings.Join(unknown, ", "))
+		}
+		_, _ = m.Reply(strings.Join(parts, "\n"))
+		return nil
+	}))
+
+	client.On("cmd:removemanager", a.require(store.RoleOwner, func(m *telegram.NewMessage) error {
+		userID, chatIDs, ok := parseManagerArgs(m.Args())
+		if !ok {
+			_, _ = m.Reply("Usage: /removemanager &lt;user_id&gt; [chat_id…]\nWithout chat IDs, the user loses every managed chat.")
+			return nil
+		}
+		before := managedState(a, userID)
+		if len(chatIDs) == 0 {
+			if err := st.RemoveManager(userID); err != nil {
+				_, _ = m.Reply("❌ Failed to remove manager: " + err.Error())
+				return err
+			}
+			au.recordMsg(m, "removemanager", before, "")
+			_, _ = m.Reply(fmt.Sprintf("✅ <code>%d</code> no longer manages any chat.", userID))
+			return nil
+		}
+		for _, chatID := range chatIDs {
+			if err := st.RemoveChatManager(chatID, userID); err != nil {
+				_, _ = m.Reply("❌ Failed to remove manager: " + err.Error())
+				return err
+			}
+		}
+		au.recordMsg(m, "removemanager", before, managedState(a, userID))
+		_, _ = m.Reply(fmt.Sprintf("✅ <code>%d</code> no longer manages %d chat(s).", userID, len(chatIDs)))
+		return nil
+	}))
+
+	client.On("cmd:managers", a.require(store.RoleAdmin, func(m *telegram.NewMessage) error {
+		managers, err := st.ChatManagers()
+		if err != nil {
+			_, _ = m.Reply("❌ Error: " + err.Error())
+			return err
+		}
+		if len(managers) == 0 {
+			_, _ = m.Reply("No chat managers assigned. Use /addmanager &lt;user_id&gt; &lt;chat_id…&gt;.")
+			return nil
+		}
+		chats, err := st.ListChats()
+		if err != nil {
+			_, _ = m.Reply("❌ Error: " + err.Error())
+			return err
+		}
+		var lines []string
+		for _, c := range chats {
+			users := managers[c.ID]
+			if len(users) == 0 {
+				continue
+			}
+			ids := make([]string, len(users))
+			for i, u := range users {
+				ids[i] = fmt.Sprintf("<code>%d</code>", u)
+			}
+			lines = append(lines, fmt.Sprintf("• <code>%d</code>: %s", c.ID, strings.Join(ids, ", ")))
+		}
+		_, _ = m.Reply("🧑‍💼 <b>Chat managers</b>\n" + strings.Join(lines, "\n"))
+		return nil
+	}))
+}
+
+func parseManagerArgs(raw string) (int64, []int64, bool) {
+	fields := strings.Fields(raw)
+	if len(fields) == 0 {
+		return 0, nil, false
+	}
+	userID, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || userID <= 0 {
+		return 0, nil, false
+	}
+	var chatIDs []int64
+	for _, f := range fields[1:] {
+		chatID, err := store.ParseChatID(f)
+		if err != nil {
+			return 0, nil, false
+		}
+		chatIDs = append(chatIDs, chatID)
+	}
+	return userID, chatIDs, true
+}
+
+func managedState(a *access, userID int64) string {
+	chats := a.managedChats(userID)
+	ids := make([]string, len(chats))
+	for i, id := range chats {
+		ids[i] = strconv.FormatInt(id, 10)
+	}
+	return strings.Join(ids, ",")
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+// notMemberTTL is how long a session whose chats couldn't be resolved is
+// left out of a chat after failing to react there because it isn't in it,
+// before it is tried again in case it joined in the meantime.
+const notMemberTTL = 30 * time.Minute
+
+// notMemberErrors mean the session cannot see the chat at all.
+var notMemberErrors = []string{
+	"CHANNEL_PRIVATE",
+	"USER_NOT_PARTICIPANT",
+	"CHAT_ID_INVALID",
+	"CHANNEL_INVALID",
+	"PEER_ID_INVALID",
+}
+
+func isNotMember(err error) bool {
+	for _, code := range notMemberErrors {
+		if telegram.MatchError(err, code) {
+			return true
+		}
+	}
+	return false
+}
+
+type memberKey struct {
+	session string
+	chatID  int64
+}
+
+// membership tracks which sessions are in which chats. Each session's chats
+// are resolved from its dialogs when it attaches and again when a chat is
+// added; receiving a message from a chat proves a session is in it and a
+// not-a-member error proves it isn't. A session whose dialogs couldn't be
+// read is tried everywhere, and left out of a chat for notMemberTTL after
+// failing there.
+type membership struct {
+	mu sync.RWMutex
+	// chats maps a resolved session to the chats it is in.
+	chats map[string]map[int64]bool
+	// absent maps memberKey to when an unresolved session was last found
+	// missing.
+	absent sync.Map
+}
+
+// resolve replaces what is known about session with the chats it is in.
+func (ms *membership) resolve(session string, chatIDs []int64) {
+	in := make(map[int64]bool, len(chatIDs))
+	for _, id := range chatIDs {
+		in[id] = true
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if ms.chats == nil {
+		ms.chats = make(map[string]map[int64]bool)
+	}
+	ms.chats[session] = in
+}
+
+func (ms *membership) joined(session string, chatID int64) {
+	ms.absent.Delete(memberKey{session, chatID})
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	if in, ok := ms.chats[session]; ok {
+		in[chatID] = true
+	}
+}
+
+func (ms *membership) left(session string, chatID int64) {
+	ms.mu.Lock()
+	in, ok := ms.chats[session]
+	if ok {
+		delete(in, chatID)
+	}
+	ms.mu.Unlock()
+	if !ok {
+		ms.absent.Store(memberKey{session, chatID}, time.Now())
+	}
+}
+
+// excluded reports whether session is known not to be in chatID.
+func (ms *membership) excluded(session string, chatID int64) bool {
+	ms.mu.RLock()
+	in, ok := ms.chats[session]
+	member := in[chatID]
+	ms.mu.RUnlock()
+	if ok {
+		return !member
+	}
+	v, ok := ms.absent.Load(memberKey{session, chatID})
+	if !ok {
+		return false
+	}
+	if time.Since(v.(time.Time)) > notMemberTTL {
+		ms.absent.Delete(memberKey{session, chatID})
+		return false
+	}
+	return true
+}
+
+// dialogFolders are the dialog lists a session's chats can be in: the main
+// list and the archive.
+var dialogFolders = []int32{0, 1}
+
+// dialogChats lists the groups and channels client has a dialog with, by
+// canonical chat ID.
+func dialogChats(client *telegram.Client) ([]int64, error) {
+	var out []int64
+	for _, folder := range dialogFolders {
+		dialogs, err := client.GetDialogs(&telegram.DialogOptions{FolderID: folder})
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range dialogs {
+			switch p := d.Peer.(type) {
+			case *telegram.PeerChannel:
+				out = append(out, store.Peer{Type: store.PeerChannel, ID: p.ChannelID}.ChatID())
+			case *telegram.PeerChat:
+				out = append(out, store.Peer{Type: store.PeerChat, ID: p.ChatID}.ChatID())
+			}
+		}
+	}
+	return out, nil
+}
+
+// resolveMembership reads the chats sess is in from its dialogs, in the
+// background. Until that succeeds, the session is treated as unresolved.
+func (r *Reactor) resolveMembership(sess *Session) {
+	client := sess.Client()
+	if client == nil {
+		return
+	}
+	go func() {
+		chats, err := dialogChats(client)
+		if err != nil {
+			slog.Warn("Failed to read the chats of session, learning them from messages", "session", sess.ID, "err", err)
+			return
+		}
+		r.members.resolve(sess.ID, chats)
+		slog.Info("Resolved the chats of session", "session", sess.ID, "chats", len(chats))
+	}()
+}
+
+// RefreshMembership resolves again which chats the connected sessions of
+// tenant are in, as after a chat was added or joined.
+func (r *Reactor) RefreshMembership(tenant string) {
+	for _, sess := range connectedSessions(sessionsOf(r.sessions, tenant)) {
+		r.resolveMembership(sess)
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+// Events receives what the userbots notice that a tenant's owners should
+// hear about. RegisterBot returns the bot's implementation; without a bot
+// events are only logged.
+type Events interface {
+	ChatMigrated(tenant string, from, to int64)
+}
+
+// SetEvents routes events from the userbots to ev.
+func (r *Reactor) SetEvents(ev Events) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = ev
+}
+
+func (r *Reactor) eventSink() Events {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.events
+}
+
+// onAction follows basic groups that Telegram upgrades to supergroups. The
+// old group gets a migrate-to message and the new supergroup a migrate-from
+// one; whichever a session sees first moves the chat, the rest find nothing
+// left to move.
+func (r *Reactor) onAction(m *telegram.NewMessage) error {
+	var from, to int64
+	switch a := m.Action.(type) {
+	case *telegram.MessageActionChatMigrateTo:
+		from = m.ChannelID()
+		to = store.Peer{Type: store.PeerChannel, ID: a.ChannelID}.ChatID()
+	case *telegram.MessageActionChannelMigrateFrom:
+		from = store.Peer{Type: store.PeerChat, ID: a.ChatID}.ChatID()
+		to = m.ChannelID()
+	default:
+		return nil
+	}
+	tenants, err := r.st.MigrateChat(from, to)
+	if !r.health.record("migrate_chat", err, "from", from, "to", to) || len(tenants) == 0 {
+		return nil
+	}
+	slog.Info("Chat upgraded to supergroup", "from", from, "to", to, "tenants", tenants)
+	if ev := r.eventSink(); ev != nil {
+		for _, t := range tenants {
+			ev.ChatMigrated(t, from, to)
+		}
+	}
+	return nil
+}
+
+// botEvents tells each tenant's owners through the bot and records the event
+// in the tenant's audit log.
+type botEvents struct {
+	client   *telegram.Client
+	auditors map[string]*auditor
+	owners   map[string][]int64
+}
+
+func (b *botEvents) ChatMigrated(tenant string, from, to int64) {
+	au, ok := b.auditors[tenant]
+	if !ok {
+		return
+	}
+	au.record(0, "migratechat", "", strconv.FormatInt(from, 10), strconv.FormatInt(to, 10))
+	b.notifyOwners(tenant, fmt.Sprintf(
+		"🔀 Chat <code>%d</code> was upgraded to a supergroup and is now <code>%d</code>. Its settings and managers moved with it.",
+		from, to,
+	))
+}
+
+// notifyOwners messages the tenant's configured owners and everyone granted
+// the owner role there, once each even when they are both. Owners who never
+// started the bot can't be reached; that is only logged.
+func (b *botEvents) notifyOwners(tenant, text string) {
+	var ids []int64
+	add := func(id int64) {
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	for _, id := range b.owners[tenant] {
+		add(id)
+	}
+	users, err := b.auditors[tenant].st.ListUsers()
+	if err != nil {
+		slog.Error("Failed to list owners to notify", "tenant", tenant, "err", err)
+	}
+	for _, u := range users {
+		if u.Role == store.RoleOwner {
+			add(u.ID)
+		}
+	}
+	for _, id := range ids {
+		if _, err := b.client.SendMessage(id, text); err != nil {
+			slog.Warn("Failed to notify owner", "tenant", tenant, "user_id", id, "err", err)
+		}
+	}
+}
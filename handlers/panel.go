@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+const (
+	panelPrefix       = "panel:"
+	panelChatsPerPage = 8
+	panelEmojisPerRow = 6
+)
+
+// Callback data layout, all prefixed with panelPrefix:
+//
+//	home | toggle | sessions
+//	chats:<page> | chat:<chat_id>:<page>
+//	emojis:<pool> | emrm:<pool>:<emoji> | emadd:<pool> | emput:<pool>:<emoji>
+type panel struct {
+	st       store.Store
+	sessions []*Session
+	access   *access
+	audit    *auditor
+}
+
+// panelMutations are the actions that change state; everything else only
+// renders a view and is open to viewers.
+var panelMutations = map[string]bool{"toggle": true, "chat": true, "emrm": true, "emput": true}
+
+func registerPanel(client *telegram.Client, st store.Store, sessions []*Session, a *access, au *auditor) {
+	p := &panel{st: st, sessions: sessions, access: a, audit: au}
+
+	client.On("cmd:panel", a.require(store.RoleViewer, func(m *telegram.NewMessage) error {
+		text, kb := p.home()
+		_, err := m.Reply(text, &telegram.SendOptions{ReplyMarkup: kb})
+		return err
+	}))
+
+	client.On("callback:"+panelPrefix, func(cb *telegram.CallbackQuery) error {
+		return p.handle(cb)
+	}, a.known())
+}
+
+func (p *panel) handle(cb *telegram.CallbackQuery) error {
+	parts := strings.SplitN(strings.TrimPrefix(cb.DataString(), panelPrefix), ":", 3)
+	if panelMutations[parts[0]] && !p.access.allowed(cb.GetSenderID(), store.RoleAdmin) {
+		_, _ = cb.Answer("⛔ Changing settings needs the admin role.", &telegram.CallbackOptions{Alert: true})
+		return nil
+	}
+	toast := ""
+	var text string
+	var kb *telegram.ReplyInlineMarkup
+
+	switch parts[0] {
+	case "home":
+		text, kb = p.home()
+	case "toggle":
+		current, err := p.st.IsEnabled()
+		if err != nil {
+			return p.fail(cb, err)
+		}
+		enabled := !current
+		if err := p.st.SetEnabled(enabled); err != nil {
+			return p.fail(cb, err)
+		}
+		p.record(cb, "toggle", onOff(!enabled), onOff(enabled))
+		toast = "Auto-reactions disabled"
+		if enabled {
+			toast = "Auto-reactions enabled"
+		}
+		text, kb = p.home()
+	case "sessions":
+		text, kb = p.sessionsView()
+	case "chats":
+		text, kb = p.chatsView(atoiArg(parts, 1))
+	case "chat":
+		chatID, err := strconv.ParseInt(arg(parts, 1), 10, 64)
+		if err != nil {
+			return p.fail(cb, err)
+		}
+		before := chatState(p.st, chatID)
+		enabled := before != "active"
+		if err := p.st.SetChatEnabled(chatID, enabled); err != nil {
+			return p.fail(cb, err)
+		}
+		p.record(cb, "chat "+arg(parts, 1), before, chatState(p.st, chatID))
+		toast = fmt.Sprintf("Chat %d paused", chatID)
+		if enabled {
+			toast = fmt.Sprintf("Chat %d resumed", chatID)
+		}
+		text, kb = p.chatsView(atoiArg(parts, 2))
+	case "emojis":
+		text, kb = p.emojisView(arg(parts, 1))
+	case "emrm":
+		pool := poolByName(p.st, arg(parts, 1))
+		if pool == nil {
+			return p.fail(cb, fmt.Errorf("unknown pool %q", arg(parts, 1)))
+		}
+		before := poolState(pool.list)
+		if err := pool.remove(arg(parts, 2)); err != nil {
+			return p.fail(cb, err)
+		}
+		p.record(cb, "emrm "+pool.name+" "+arg(parts, 2), before, poolState(pool.list))
+		toast = "Removed " + arg(parts, 2)
+		text, kb = p.emojisView(pool.name)
+	case "emadd":
+		text, kb = p.emojiPicker(arg(parts, 1))
+	case "emput":
+		pool := poolByName(p.st, arg(parts, 1))
+		if pool == nil {
+			return p.fail(cb, fmt.Errorf("unknown pool %q", arg(parts, 1)))
+		}
+		emoji := arg(parts, 2)
+		if !IsValidReaction(emoji) {
+			return p.fail(cb, fmt.Errorf("%s is not a valid reaction", emoji))
+		}
+		before := poolState(pool.list)
+		if err := pool.add(emoji); err != nil {
+			return p.fail(cb, err)
+		}
+		p.record(cb, "emput "+pool.name+" "+emoji, before, poolState(pool.list))
+		toast = "Added " + emoji
+		text, kb = p.emojiPicker(pool.name)
+	default:
+		_, _ = cb.Answer("Unknown action")
+		return nil
+	}
+
+	_, _ = cb.Answer(toast)
+	_, err := cb.Edit(text, &telegram.SendOptions{ReplyMarkup: kb})
+	if telegram.MatchError(err, "MESSAGE_NOT_MODIFIED") {
+		return nil
+	}
+	return err
+}
+
+// record audits a panel mutation. Panel actions are logged as "panel" with
+// the action in the arguments, so they can be told apart from commands.
+func (p *panel) record(cb *telegram.CallbackQuery, action, before, after string) {
+	p.audit.record(cb.GetSenderID(), "panel", action, before, after)
+}
+
+func (p *panel) fail(cb *telegram.CallbackQuery, err error) error {
+	_, _ = cb.Answer("❌ "+err.Error(), &telegram.CallbackOptions{Alert: true})
+	return err
+}
+
+func (p *panel) home() (string, *telegram.ReplyInlineMarkup) {
+	enabled, err := p.st.IsEnabled()
+	if err != nil {
+		return "❌ Error: " + html.EscapeString(err.Error()), backKeyboard()
+	}
+	chats, err := p.st.ListChats()
+	if err != nil {
+		return "❌ Error: " + html.EscapeString(err.Error()), backKeyboard()
+	}
+	active := 0
+	for _, c := range chats {
+		if c.Enabled {
+			active++
+		}
+	}
+	state, toggle := "🚫 OFF", "✅ Turn auto-react ON"
+	if enabled {
+		state, toggle = "✅ ON", "🚫 Turn auto-react OFF"
+	}
+	text := fmt.Sprintf(
+		"🎛 <b>ReactionBot panel</b>\n\nAuto-react: %s\nChats: %d active / %d total\nSessions connected: %d/%d",
+		state, active, len(chats), len(connectedSessions(p.sessions)), len(p.sessions),
+	)
+	kb := telegram.NewKeyboard().
+		AddRow(telegram.Button.Data(toggle, panelPrefix+"toggle")).
+		AddRow(
+			telegram.Button.Data("📋 Chats", panelPrefix+"chats:0"),
+			telegram.Button.Data("😀 Emojis", panelPrefix+"emojis:prem"),
+		).
+		AddRow(
+			telegram.Button.Data("🔌 Sessions", panelPrefix+"sessions"),
+			telegram.Button.Data("🔄 Refresh", panelPrefix+"home"),
+		).
+		Build()
+	return text, kb
+}
+
+func (p *panel) chatsView(page int) (string, *telegram.ReplyInlineMarkup) {
+	chats, err := p.st.ListChats()
+	if err != nil {
+		return "❌ Error: " + html.EscapeString(err.Error()), backKeyboard()
+	}
+	if len(chats) == 0 {
+		return "📋 No chats added yet. Use /addchat &lt;chat_id&gt;.", backKeyboard()
+	}
+	pages := (len(chats) + panelChatsPerPage - 1) / panelChatsPerPage
+	page = max(0, min(page, pages-1))
+	kb := telegram.NewKeyboard()
+	for _, c := range chats[page*panelChatsPerPage : min(len(chats), (page+1)*panelChatsPerPage)] {
+		label := "⏸ " + strconv.FormatInt(c.ID, 10)
+		if c.Enabled {
+			label = "✅ " + strconv.FormatInt(c.ID, 10)
+		}
+		kb.AddRow(telegram.Button.Data(label, fmt.Sprintf("%schat:%d:%d", panelPrefix, c.ID, page)))
+	}
+	var nav []telegram.KeyboardButton
+	if page > 0 {
+		nav = append(nav, telegram.Button.Data("◀️ Prev", fmt.Sprintf("%schats:%d", panelPrefix, page-1)))
+	}
+	if page < pages-1 {
+		nav = append(nav, telegram.Button.Data("Next ▶️", fmt.Sprintf("%schats:%d", panelPrefix, page+1)))
+	}
+	if len(nav) > 0 {
+		kb.AddRow(nav...)
+	}
+	kb.AddRow(telegram.Button.Data("⬅️ Back", panelPrefix+"home"))
+	text := fmt.Sprintf("📋 <b>Monitored chats</b> (page %d/%d)\nTap a chat to pause or resume it.", page+1, pages)
+	return text, kb.Build()
+}
+
+func (p *panel) emojisView(name string) (string, *telegram.ReplyInlineMarkup) {
+	pool := poolByName(p.st, name)
+	if pool == nil {
+		pool = poolByName(p.st, "prem")
+	}
+	emojis, err := pool.list()
+	if err != nil {
+		return "❌ Error: " + html.EscapeString(err.Error()), backKeyboard()
+	}
+	kb := telegram.NewKeyboard()
+	for _, row := range poolTabs(pool.name, "emojis") {
+		kb.AddRow(row...)
+	}
+	var buttons []telegram.KeyboardButton
+	for _, e := range emojis {
+		buttons = append(buttons, telegram.Button.Data("❌ "+e, panelPrefix+"emrm:"+pool.name+":"+e))
+	}
+	kb.NewColumn(panelEmojisPerRow, buttons...)
+	kb.AddRow(
+		telegram.Button.Data("➕ Add", panelPrefix+"emadd:"+pool.name),
+		telegram.Button.Data("⬅️ Back", panelPrefix+"home"),
+	)
+	text := fmt.Sprintf("😀 <b>%s pool</b> (%d)\nTap an emoji to remove it.", pool.title, len(emojis))
+	if pool.fallback != "" && len(emojis) == 0 {
+		text += fmt.Sprintf("\nWhile empty, comments use the %s pool.", poolTitles[pool.fallback])
+	}
+	return text, kb.Build()
+}
+
+func (p *panel) emojiPicker(name string) (string, *telegram.ReplyInlineMarkup) {
+	pool := poolByName(p.st, name)
+	if pool == nil {
+		pool = poolByName(p.st, "prem")
+	}
+	current, err := pool.list()
+	if err != nil {
+		return "❌ Error: " + html.EscapeString(err.Error()), backKeyboard()
+	}
+	have := make(map[string]struct{}, len(current))
+	for _, e := range current {
+		have[stripVariationSelector(e)] = struct{}{}
+	}
+	valid := ValidReactionList()
+	slices.Sort(valid)
+	var buttons []telegram.KeyboardButton
+	for _, e := range valid {
+		if _, ok := have[e]; ok {
+			continue
+		}
+		buttons = append(buttons, telegram.Button.Data(e, panelPrefix+"emput:"+pool.name+":"+e))
+	}
+	kb := telegram.NewKeyboard().NewColumn(panelEmojisPerRow+2, buttons...)
+	kb.AddRow(telegram.Button.Data("✔️ Done", panelPrefix+"emojis:"+pool.name))
+	text := fmt.Sprintf("➕ <b>Add to %s pool</b>\nTap a reaction to add it.", pool.title)
+	return text, kb.Build()
+}
+
+func (p *panel) sessionsView() (string, *telegram.ReplyInlineMarkup) {
+	kb := telegram.NewKeyboard().AddRow(
+		telegram.Button.Data("🔄 Refresh", panelPrefix+"sessions"),
+		telegram.Button.Data("⬅️ Back", panelPrefix+"home"),
+	).Build()
+	if len(p.sessions) == 0 {
+		return "🔌 No userbot sessions configured.", kb
+	}
+	return formatSessionGroups(p.sessions), kb
+}
+
+var poolTitles = map[string]string{
+	"prem":   "⭐ Premium",
+	"nprem":  "👤 Non-premium",
+	"cprem":  "💬⭐ Comment premium",
+	"cnprem": "💬👤 Comment non-premium",
+}
+
+type emojiPool struct {
+	name, title string
+	list        func() ([]string, error)
+	add, remove func(string) error
+	// fallback is the pool used while this one is empty.
+	fallback string
+}
+
+func poolByName(st store.Store, name string) *emojiPool {
+	switch name {
+	case "prem":
+		return &emojiPool{name: "prem", title: poolTitles["prem"], list: st.GetPremEmojis, add: st.AddPremEmoji, remove: st.RemovePremEmoji}
+	case "nprem":
+		return &emojiPool{name: "nprem", title: poolTitles["nprem"], list: st.GetNpremEmojis, add: st.AddNpremEmoji, remove: st.RemoveNpremEmoji}
+	case "cprem", "cnprem":
+		premium := name == "cprem"
+		return &emojiPool{
+			name:     name,
+			title:    poolTitles[name],
+			list:     func() ([]string, error) { return st.GetCommentEmojis(premium) },
+			add:      func(e string) error { return st.AddCommentEmoji(premium, e) },
+			remove:   func(e string) error { return st.RemoveCommentEmoji(premium, e) },
+			fallback: strings.TrimPrefix(name, "c"),
+		}
+	}
+	return nil
+}
+
+// poolTabs returns a row of post pool tabs and a row of comment pool tabs.
+func poolTabs(active, view string) [][]telegram.KeyboardButton {
+	var rows [][]telegram.KeyboardButton
+	for _, names := range [][]string{{"prem", "nprem"}, {"cprem", "cnprem"}} {
+		var tabs []telegram.KeyboardButton
+		for _, name := range names {
+			label := poolTitles[name]
+			if name == active {
+				label = "• " + label + " •"
+			}
+			tabs = append(tabs, telegram.Button.Data(label, panelPrefix+view+":"+name))
+		}
+		rows = append(rows, tabs)
+	}
+	return rows
+}
+
+func backKeyboard() *telegram.ReplyInlineMarkup {
+	return telegram.NewKeyboard().AddRow(telegram.Button.Data("⬅️ Back", panelPrefix+"home")).Build()
+}
+
+func arg(parts []string, i int) string {
+	if i < len(parts) {
+		return parts[i]
+	}
+	return ""
+}
+
+func atoiArg(parts []string, i int) int {
+	n, _ := strconv.Atoi(arg(parts, i))
+	return n
+}
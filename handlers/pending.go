@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+// pendingMaxAge is how old a saved reaction may be and still be sent on the
+// next start; reacting to day-old messages would only look odd.
+const pendingMaxAge = 6 * time.Hour
+
+// loadPending takes the reactions the previous run saved and keeps those of
+// known sessions until the session attaches.
+func (r *Reactor) loadPending() error {
+	saved, err := r.st.TakePendingReactions()
+	if err != nil {
+		return err
+	}
+	known := make(map[string]bool, len(r.sessions))
+	for _, sess := range r.sessions {
+		known[sess.ID] = true
+	}
+	pending := make(map[string][]store.PendingReaction)
+	kept := 0
+	for _, p := range saved {
+		if !known[p.SessionID] || time.Since(p.QueuedAt) > pendingMaxAge {
+			continue
+		}
+		pending[p.SessionID] = append(pending[p.SessionID], p)
+		kept++
+	}
+	if len(saved) > 0 {
+		slog.Info("Loaded reactions saved at shutdown", "saved", len(saved), "replaying", kept)
+	}
+	r.mu.Lock()
+	r.pending = pending
+	r.mu.Unlock()
+	return nil
+}
+
+// replay queues the saved reactions of sess, once, in the background so a
+// full queue doesn't hold up the connect.
+func (r *Reactor) replay(sess *Session) {
+	r.mu.Lock()
+	pending := r.pending[sess.ID]
+	delete(r.pending, sess.ID)
+	r.mu.Unlock()
+	if len(pending) == 0 {
+		return
+	}
+	go func() {
+		sent := 0
+		for i, p := range pending {
+			if p.Tenant != sess.Tenant {
+				continue
+			}
+			st := r.st.ForTenant(p.Tenant)
+			chat, _, ok, err := r.target(st, p.ChatID)
+			if !r.health.record("get_chat", err, "tenant", p.Tenant, "chat_id", p.ChatID) || !ok || !chat.Enabled {
+				continue
+			}
+			if !r.queue.submit(reactionJob{st: st, sess: sess, chatID: p.ChatID, msgID: p.MsgID, kind: p.Kind, queued: p.QueuedAt}) {
+				r.keepPending(sess.ID, pending[i:])
+				break
+			}
+			sent++
+		}
+		slog.Info("Replaying reactions saved at shutdown", "session", sess.ID, "reactions", sent)
+	}()
+}
+
+// keepPending puts back saved reactions that could not be queued because
+// the queue was shutting down, so they are saved again for the next start.
+// If Shutdown has already collected the unreplayed ones, they are saved
+// directly.
+func (r *Reactor) keepPending(session string, rest []store.PendingReaction) {
+	r.mu.Lock()
+	if r.pending != nil {
+		r.pending[session] = append(rest, r.pending[session]...)
+		r.mu.Unlock()
+		return
+	}
+	r.mu.Unlock()
+	r.savePending(nil, rest)
+}
+
+// unreplayed takes back the saved reactions whose session never attached
+// during this run, such as one that stayed offline or was retired, leaving
+// out those that have grown too old to send.
+func (r *Reactor) unreplayed() []store.PendingReaction {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = nil
+	r.mu.Unlock()
+	var out []store.PendingReaction
+	expired := 0
+	for _, list := range pending {
+		for _, p := range list {
+			if time.Since(p.QueuedAt) > pendingMaxAge {
+				expired++
+				continue
+			}
+			out = append(out, p)
+		}
+	}
+	if len(out) > 0 || expired > 0 {
+		slog.Warn("Some sessions never connected, keeping their saved reactions", "kept", len(out), "expired", expired)
+	}
+	return out
+}
+
+// savePending stores the reactions that were still queued at shutdown,
+// together with the saved ones that were never replayed.
+func (r *Reactor) savePending(jobs []reactionJob, unreplayed []store.PendingReaction) {
+	pending := unreplayed
+	for _, j := range jobs {
+		pending = append(pending, store.PendingReaction{
+			Tenant:    j.st.Tenant(),
+			SessionID: j.sess.ID,
+			ChatID:    j.chatID,
+			MsgID:     j.msgID,
+			Kind:      j.kind,
+			QueuedAt:  j.queued,
+		})
+	}
+	if len(pending) == 0 {
+		return
+	}
+	if r.health.record("save_pending_reactions", r.st.SavePendingReactions(pending)) {
+		slog.Info("Saved queued reactions for the next start", "reactions", len(pending))
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+func newPendingTest(t *testing.T) (store.Store, []store.PendingReaction) {
+	t.Helper()
+	st, err := store.New(filepath.Join(t.TempDir(), "reactions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	const chatID = -1001
+	if err := st.AddChat(chatID, store.DefaultChatDefaults); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Truncate(time.Second)
+	var saved []store.PendingReaction
+	for i := range 3 {
+		saved = append(saved, store.PendingReaction{Tenant: store.DefaultTenant, SessionID: "s1", ChatID: chatID, MsgID: int32(i + 1), QueuedAt: now})
+	}
+	return st, saved
+}
+
+// TestReplayDuringShutdown replays saved reactions into a queue that is
+// already shutting down; they must be saved again, not dropped.
+func TestReplayDuringShutdown(t *testing.T) {
+	st, saved := newPendingTest(t)
+	if err := st.SavePendingReactions(saved); err != nil {
+		t.Fatal(err)
+	}
+	sess := NewSession("s1", false)
+	r := Register(context.Background(), []*Session{sess}, st, PoolConfig{Workers: 1, Queue: 1})
+	r.queue.Shutdown(0)
+	r.replay(sess)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		r.mu.RLock()
+		n := len(r.pending[sess.ID])
+		r.mu.RUnlock()
+		if n == len(saved) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d reactions put back, want %d", n, len(saved))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	r.Shutdown(0)
+	if got, err := st.TakePendingReactions(); err != nil || len(got) != len(saved) {
+		t.Errorf("TakePendingReactions() = %d reactions, %v, want %d", len(got), err, len(saved))
+	}
+}
+
+// TestKeepPendingAfterShutdown puts reactions back after Shutdown has
+// already saved the unreplayed ones; they are saved directly.
+func TestKeepPendingAfterShutdown(t *testing.T) {
+	st, saved := newPendingTest(t)
+	r := Register(context.Background(), nil, st, PoolConfig{Workers: 1, Queue: 1})
+	r.Shutdown(0)
+	r.keepPending("s1", saved)
+	if got, err := st.TakePendingReactions(); err != nil || len(got) != len(saved) {
+		t.Errorf("TakePendingReactions() = %d reactions, %v, want %d", len(got), err, len(saved))
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+const (
+	defaultWorkers = 8
+	defaultQueue   = 1024
+	// fullQueueLogEvery rate-limits the warning logged while the queue is
+	// full, since it fires on every update until the workers catch up.
+	fullQueueLogEvery = time.Minute
+)
+
+// PoolConfig sizes the worker pool that sends reactions. Zero values take
+// the defaults.
+type PoolConfig struct {
+	Workers int
+	Queue   int
+}
+
+// reactionJob is one session reacting to one message.
+type reactionJob struct {
+	st     store.Store
+	sess   *Session
+	chatID int64
+	msgID  int32
+	kind   store.Kind
+	queued time.Time
+	// reserved, when set, is when a unit of sess's quota was reserved for
+	// the job; replayed jobs have none and reserve as they send.
+	reserved time.Time
+}
+
+// ReactionQueue hands reaction jobs from the update handlers to a fixed set
+// of workers. When the queue is full, submit blocks the update handler until
+// a worker frees a slot; how often and how long that happens is counted so
+// /status shows when more workers are needed.
+type ReactionQueue struct {
+	jobs    chan reactionJob
+	workers int
+	run     func(context.Context, reactionJob)
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	stop   chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+
+	busy      atomic.Int64
+	handled   atomic.Int64
+	blocked   atomic.Int64
+	blockedNs atomic.Int64
+	lastWarn  atomic.Int64
+
+	// left collects the jobs that were never run because of a shutdown.
+	leftMu sync.Mutex
+	left   []reactionJob
+}
+
+func newReactionQueue(cfg PoolConfig, run func(context.Context, reactionJob)) *ReactionQueue {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.Queue <= 0 {
+		cfg.Queue = defaultQueue
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &ReactionQueue{
+		jobs:    make(chan reactionJob, cfg.Queue),
+		workers: cfg.Workers,
+		run:     run,
+		ctx:     ctx,
+		cancel:  cancel,
+		stop:    make(chan struct{}),
+	}
+	for range cfg.Workers {
+		q.wg.Add(1)
+		go q.work()
+	}
+	return q
+}
+
+// submit queues job, waiting for room when the queue is full. Once the
+// queue is shutting down the job is set aside for Shutdown to return, and
+// submit reports false.
+func (q *ReactionQueue) submit(job reactionJob) bool {
+	select {
+	case <-q.stop:
+		q.setAside(job)
+		return false
+	default:
+	}
+	select {
+	case q.jobs <- job:
+		return true
+	default:
+	}
+	start := time.Now()
+	q.blocked.Add(1)
+	if last := q.lastWarn.Load(); start.UnixNano()-last > int64(fullQueueLogEvery) && q.lastWarn.CompareAndSwap(last, start.UnixNano()) {
+		slog.Warn("Reaction queue full, updates are waiting", "queue", cap(q.jobs), "workers", q.workers)
+	}
+	defer func() { q.blockedNs.Add(int64(time.Since(start))) }()
+	select {
+	case q.jobs <- job:
+		return true
+	case <-q.stop:
+		q.setAside(job)
+		return false
+	}
+}
+
+func (q *ReactionQueue) setAside(job reactionJob) {
+	q.leftMu.Lock()
+	defer q.leftMu.Unlock()
+	q.left = append(q.left, job)
+}
+
+func (q *ReactionQueue) work() {
+	defer q.wg.Done()
+	for {
+		select {
+		case job := <-q.jobs:
+			q.do(job)
+		case <-q.stop:
+			// Drain what was queued before the stop; cancel makes the
+			// remaining jobs return at once if the grace period runs out.
+			for {
+				select {
+				case job := <-q.jobs:
+					q.do(job)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (q *ReactionQueue) do(job reactionJob) {
+	if q.ctx.Err() != nil {
+		q.setAside(job)
+		return
+	}
+	q.busy.Add(1)
+	defer q.busy.Add(-1)
+	q.run(q.ctx, job)
+	q.handled.Add(1)
+}
+
+// Shutdown stops taking jobs and gives the workers grace to finish the
+// queued ones. Jobs still waiting after that are cancelled and returned, as
+// are any submitted during the shutdown. It returns once every worker has
+// exited; later calls return nothing.
+func (q *ReactionQueue) Shutdown(grace time.Duration) []reactionJob {
+	var left []reactionJob
+	q.once.Do(func() {
+		close(q.stop)
+		done := make(chan struct{})
+		go func() {
+			q.wg.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(grace):
+			slog.Warn("Reaction queue not drained in time, cancelling the rest", "grace", grace, "pending", len(q.jobs))
+			q.cancel()
+			<-done
+		}
+		q.cancel()
+		// A submit racing the stop can still land a job after the workers
+		// have drained the channel.
+		for len(q.jobs) > 0 {
+			q.setAside(<-q.jobs)
+		}
+		q.leftMu.Lock()
+		left, q.left = q.left, nil
+		q.leftMu.Unlock()
+		slog.Info("Reaction queue stopped", "handled", q.handled.Load(), "left", len(left))
+	})
+	return left
+}
+
+// Status is a one-line summary for /status.
+func (q *ReactionQueue) Status() string {
+	s := fmt.Sprintf("%d/%d queued, %d/%d workers busy", len(q.jobs), cap(q.jobs), q.busy.Load(), q.workers)
+	if n := q.blocked.Load(); n > 0 {
+		s += fmt.Sprintf(", full %d time(s) for %s in total", n, time.Duration(q.blockedNs.Load()).Round(time.Millisecond))
+	}
+	return s
+}
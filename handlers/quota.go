@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+// Quota caps the reactions one session sends per clock hour and per UTC day.
+// Zero means no cap.
+type Quota struct {
+	Hourly int
+	Daily  int
+}
+
+// usage counts a session's reactions in the current hour and day.
+type usage struct {
+	hour, day           int64 // unix hour and day the counts belong to
+	hourCount, dayCount int
+}
+
+func (u *usage) roll(now time.Time) {
+	hour, day := now.Unix()/3600, now.Unix()/86400
+	if u.day != day {
+		u.day, u.dayCount = day, 0
+	}
+	if u.hour != hour {
+		u.hour, u.hourCount = hour, 0
+	}
+}
+
+// remaining returns how many reactions the quota still allows this hour and
+// today, or -1 for a dimension without a cap.
+func (s *Session) remaining(now time.Time) (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usage.roll(now)
+	hour, day := -1, -1
+	if s.Quota.Hourly > 0 {
+		hour = max(s.Quota.Hourly-s.usage.hourCount, 0)
+	}
+	if s.Quota.Daily > 0 {
+		day = max(s.Quota.Daily-s.usage.dayCount, 0)
+	}
+	return hour, day
+}
+
+// reserve takes one reaction off the quota if any is left. Checking and
+// counting in one step keeps concurrent sends for the same session from
+// overshooting the caps together. A reservation that ends up unused must
+// be given back with release.
+func (s *Session) reserve(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usage.roll(now)
+	if (s.Quota.Hourly > 0 && s.usage.hourCount >= s.Quota.Hourly) || (s.Quota.Daily > 0 && s.usage.dayCount >= s.Quota.Daily) {
+		return false
+	}
+	s.usage.hourCount++
+	s.usage.dayCount++
+	return true
+}
+
+// release gives back a reservation made at at, unless its hour or day has
+// already rolled over.
+func (s *Session) release(at time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.usage.roll(time.Now())
+	if s.usage.hour == at.Unix()/3600 && s.usage.hourCount > 0 {
+		s.usage.hourCount--
+	}
+	if s.usage.day == at.Unix()/86400 && s.usage.dayCount > 0 {
+		s.usage.dayCount--
+	}
+}
+
+// loadUsage restores today's counters from the store, so quotas hold across
+// restarts.
+func loadUsage(st store.Store, sessions []*Session) error {
+	now := time.Now()
+	buckets, err := st.SessionUsage(now.Truncate(24 * time.Hour))
+	if err != nil {
+		return err
+	}
+	counts := make(map[string]*usage, len(sessions))
+	for _, sess := range sessions {
+		counts[sess.ID] = &usage{hour: now.Unix() / 3600, day: now.Unix() / 86400}
+	}
+	for _, b := range buckets {
+		u, ok := counts[b.SessionID]
+		if !ok {
+			continue
+		}
+		u.dayCount += b.Count
+		if b.Hour.Unix()/3600 == u.hour {
+			u.hourCount += b.Count
+		}
+	}
+	for _, sess := range sessions {
+		sess.mu.Lock()
+		sess.usage = *counts[sess.ID]
+		sess.mu.Unlock()
+	}
+	return nil
+}
+
+// formatQuota is the /sessions suffix showing what is left of a capped quota.
+func formatQuota(sess *Session) string {
+	if sess.Quota == (Quota{}) {
+		return ""
+	}
+	hour, day := sess.remaining(time.Now())
+	return fmt.Sprintf(" · 📊 %s/h, %s/day left", quotaLeft(hour, sess.Quota.Hourly), quotaLeft(day, sess.Quota.Daily))
+}
+
+func quotaLeft(left, limit int) string {
+	if left < 0 {
+		return "∞"
+	}
+	return fmt.Sprintf("%d of %d", left, limit)
+}
+
+func registerQuotaCommands(client *telegram.Client, st store.Store, a *access, au *auditor) {
+	const usage = "Usage: /reactors &lt;chat_id&gt; &lt;n|all&gt;\nWith n, only n sessions that are in the chat and under quota react to each message."
+	client.On("cmd:reactors", a.requireChat(usage, func(m *telegram.NewMessage, chatID int64) error {
+		args := strings.Fields(m.Args())
+		if len(args) != 2 {
+			_, _ = m.Reply(usage)
+			return nil
+		}
+		n := 0
+		if !strings.EqualFold(args[1], "all") {
+			var err error
+			if n, err = strconv.Atoi(args[1]); err != nil || n < 1 {
+				_, _ = m.Reply("❌ Invalid count.\n" + usage)
+				return nil
+			}
+		}
+		old, err := st.ChatReactors(chatID)
+		if err != nil {
+			_, _ = m.Reply("❌ Error: " + err.Error())
+			return err
+		}
+		if err := st.SetChatReactors(chatID, n); err != nil {
+			if errors.Is(err, store.ErrChatNotFound) {
+				_, _ = m.Reply(fmt.Sprintf("❌ Chat %d is not in the auto-react list.", chatID))
+				return nil
+			}
+			_, _ = m.Reply("❌ Failed to set reactors: " + err.Error())
+			return err
+		}
+		au.recordMsg(m, "reactors", reactorsState(old), reactorsState(n))
+		_, _ = m.Reply(fmt.Sprintf("✅ Chat %d: %s react to each message.", chatID, reactorsState(n)))
+		return nil
+	}))
+}
+
+func reactorsState(n int) string {
+	if n == 0 {
+		return "all eligible sessions"
+	}
+	return fmt.Sprintf("%d eligible sessions", n)
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+func TestReserveConcurrentAtCap(t *testing.T) {
+	sess := NewSession("s1", false)
+	sess.Quota = Quota{Hourly: 10, Daily: 15}
+	now := time.Now()
+
+	var granted atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Go(func() {
+			if sess.reserve(now) {
+				granted.Add(1)
+			}
+		})
+	}
+	wg.Wait()
+	if n := granted.Load(); n != 10 {
+		t.Fatalf("%d reservations granted, want the hourly cap of 10", n)
+	}
+	if hour, day := sess.remaining(now); hour != 0 || day != 5 {
+		t.Errorf("remaining() = %d, %d, want 0, 5", hour, day)
+	}
+	sess.release(now)
+	if !sess.reserve(now) {
+		t.Error("a released reservation could not be taken again")
+	}
+}
+
+func TestReleaseAcrossHourRollover(t *testing.T) {
+	sess := NewSession("s1", false)
+	sess.Quota = Quota{Hourly: 1}
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+
+	if !sess.reserve(earlier) {
+		t.Fatal("reserve() = false on an unused quota")
+	}
+	if !sess.reserve(now) {
+		t.Fatal("reserve() = false in a new hour")
+	}
+	// The earlier reservation belongs to an hour that is over; giving it
+	// back must not free this hour's only unit.
+	sess.release(earlier)
+	if hour, _ := sess.remaining(now); hour != 0 {
+		t.Errorf("%d left this hour after releasing last hour's reservation, want 0", hour)
+	}
+	sess.release(now)
+	if hour, _ := sess.remaining(now); hour != 1 {
+		t.Errorf("%d left this hour after releasing its reservation, want 1", hour)
+	}
+}
+
+func TestLoadUsage(t *testing.T) {
+	st, err := store.New(filepath.Join(t.TempDir(), "reactions.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer st.Close()
+
+	now := time.Now()
+	earlier, yesterday := now.Add(-time.Hour), now.Add(-24*time.Hour)
+	for _, b := range []struct {
+		id string
+		at time.Time
+		n  int
+	}{
+		{"s1", now, 3},
+		{"s1", earlier, 2},
+		{"s1", yesterday, 7},
+		{"gone", now, 9},
+	} {
+		if err := st.AddSessionUsage(b.id, b.at, b.n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s1, s2 := NewSession("s1", false), NewSession("s2", false)
+	s1.Quota = Quota{Hourly: 10, Daily: 20}
+	s2.Quota = s1.Quota
+	if err := loadUsage(st, []*Session{s1, s2}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bucket an hour ago counts towards today unless it fell on
+	// yesterday's date.
+	usedToday := 3
+	if earlier.Unix()/86400 == now.Unix()/86400 {
+		usedToday += 2
+	}
+	if hour, day := s1.remaining(now); hour != 7 || day != 20-usedToday {
+		t.Errorf("s1 remaining() = %d, %d, want 7, %d", hour, day, 20-usedToday)
+	}
+	if hour, day := s2.remaining(now); hour != 10 || day != 20 {
+		t.Errorf("s2 remaining() = %d, %d, want an untouched quota", hour, day)
+	}
+}
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+const (
+	minReconnectDelay   = 2 * time.Second
+	maxReconnectDelay   = 5 * time.Minute
+	healthCheckInterval = 30 * time.Second
+)
+
+type SessionState string
+
+const (
+	StateConnecting   SessionState = "connecting"
+	StateConnected    SessionState = "connected"
+	StateReconnecting SessionState = "reconnecting"
+	StateRetired      SessionState = "retired"
+)
+
+// revokedErrors are RPC errors after which an auth key can never be used
+// again, so reconnecting would only hammer Telegram with dead credentials.
+var revokedErrors = []string{
+	"AUTH_KEY_UNREGISTERED",
+	"AUTH_KEY_INVALID",
+	"AUTH_KEY_DUPLICATED",
+	"SESSION_REVOKED",
+	"SESSION_EXPIRED",
+	"USER_DEACTIVATED",
+	"USER_DEACTIVATED_BAN",
+}
+
+func isRevoked(err error) bool {
+	for _, code := range revokedErrors {
+		if telegram.MatchError(err, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// Dialer creates, connects and authorizes a fresh client for a session.
+type Dialer func() (*telegram.Client, *telegram.UserObj, error)
+
+type Session struct {
+	ID        string
+	IsPremium bool
+	// Tenant owns the chats this session reacts in.
+	Tenant string
+	// Quota caps the reactions the session sends.
+	Quota Quota
+
+	mu     sync.RWMutex
+	tags   []string
+	usage  usage
+	client *telegram.Client
+	userID int64
+	name   string
+	state  SessionState
+	reason string
+	faults chan error
+}
+
+func NewSession(id string, isPremium bool) *Session {
+	return &Session{
+		ID:        id,
+		IsPremium: isPremium,
+		Tenant:    store.DefaultTenant,
+		state:     StateConnecting,
+		faults:    make(chan error, 1),
+	}
+}
+
+// Client returns the live client, or nil while the session is not connected.
+func (s *Session) Client() *telegram.Client {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.client
+}
+
+func (s *Session) State() (SessionState, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state, s.reason
+}
+
+func (s *Session) User() (int64, string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.userID, s.name
+}
+
+func (s *Session) reportError(err error) {
+	if !isRevoked(err) {
+		return
+	}
+	select {
+	case s.faults <- err:
+	default:
+	}
+}
+
+func (s *Session) setClient(client *telegram.Client, me *telegram.UserObj) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.client = client
+	s.userID = me.ID
+	s.name = displayName(me)
+	s.state = StateConnected
+	s.reason = ""
+}
+
+func (s *Session) drop(state SessionState, reason string) {
+	s.mu.Lock()
+	client := s.client
+	s.client = nil
+	s.state = state
+	s.reason = reason
+	s.mu.Unlock()
+	if client != nil {
+		_ = client.Stop()
+	}
+}
+
+func displayName(u *telegram.UserObj) string {
+	name := u.FirstName
+	if u.LastName != "" {
+		name += " " + u.LastName
+	}
+	if name == "" && u.Username != "" {
+		name = "@" + u.Username
+	}
+	return name
+}
+
+// Supervisor keeps every session connected, recreating its client with
+// exponential backoff, and retires sessions whose auth key was revoked.
+type Supervisor struct {
+	st        store.Store
+	onConnect func(*Session)
+	wg        sync.WaitGroup
+}
+
+func NewSupervisor(st store.Store, onConnect func(*Session)) *Supervisor {
+	return &Supervisor{st: st, onConnect: onConnect}
+}
+
+// Start makes the first connection attempt synchronously and then keeps the
+// session alive in the background until ctx is cancelled. It reports whether
+// the session is connected on return.
+func (sv *Supervisor) Start(ctx context.Context, sess *Session, dial Dialer) bool {
+	rec, ok, err := sv.st.GetSession(sess.ID)
+	if err != nil {
+		slog.Error("Failed to load session", "session", sess.ID, "err", err)
+	} else if ok && rec.Retired {
+		slog.Warn("Session is retired, skipping", "session", sess.ID, "reason", rec.Reason)
+		sess.drop(StateRetired, rec.Reason)
+		return false
+	}
+
+	connected := sv.connect(sess, dial) == nil
+	if state, _ := sess.State(); state == StateRetired {
+		return false
+	}
+	sv.wg.Add(1)
+	go sv.run(ctx, sess, dial)
+	return connected
+}
+
+// Wait blocks until every supervised session has shut its client down.
+func (sv *Supervisor) Wait() {
+	sv.wg.Wait()
+}
+
+func (sv *Supervisor) run(ctx context.Context, sess *Session, dial Dialer) {
+	defer sv.wg.Done()
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	delay := minReconnectDelay
+	for {
+		if state, _ := sess.State(); state == StateRetired {
+			return
+		}
+		client := sess.Client()
+		if client == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(withJitter(delay)):
+			}
+			if err := sv.connect(sess, dial); err != nil {
+				delay = min(delay*2, maxReconnectDelay)
+			} else {
+				delay = minReconnectDelay
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			sess.drop(StateReconnecting, "shutting down")
+			return
+		case err := <-sess.faults:
+			sv.fault(sess, err)
+		case <-ticker.C:
+			if _, err := client.IsAuthorized(); err != nil {
+				sv.fault(sess, err)
+			} else if !client.IsConnected() {
+				sv.fault(sess, errors.New("connection lost"))
+			}
+		}
+	}
+}
+
+func (sv *Supervisor) connect(sess *Session, dial Dialer) error {
+	client, me, err := dial()
+	if err != nil {
+		if isRevoked(err) {
+			sv.retire(sess, err)
+		} else {
+			slog.Warn("Session failed to connect", "session", sess.ID, "err", err)
+			sess.drop(StateReconnecting, err.Error())
+		}
+		return err
+	}
+	sess.setClient(client, me)
+	if err := sv.st.RecordSession(sess.ID, me.ID, sess.IsPremium); err != nil {
+		slog.Error("Failed to record session", "session", sess.ID, "err", err)
+	}
+	slog.Info("Session logged in", "session", sess.ID, "name", displayName(me), "user_id", me.ID, "premium", sess.IsPremium)
+	sv.onConnect(sess)
+	return nil
+}
+
+func (sv *Supervisor) fault(sess *Session, err error) {
+	if isRevoked(err) {
+		sv.retire(sess, err)
+		return
+	}
+	slog.Warn("Session lost its connection, reconnecting", "session", sess.ID, "err", err)
+	sess.drop(StateReconnecting, err.Error())
+}
+
+func (sv *Supervisor) retire(sess *Session, cause error) {
+	reason := cause.Error()
+	for _, code := range revokedErrors {
+		if telegram.MatchError(cause, code) {
+			reason = code
+			break
+		}
+	}
+	slog.Error("Session retired permanently", "session", sess.ID, "err", cause)
+	sess.drop(StateRetired, reason)
+	if err := sv.st.RetireSession(sess.ID, reason); err != nil {
+		slog.Error("Failed to record retired session", "session", sess.ID, "err", err)
+	}
+}
+
+func withJitter(d time.Duration) time.Duration {
+	return d + rand.N(d/5+1)
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"github.com/sandeep97217890-droid/ReactionBot/store"
+)
+
+// tagPrefix marks a selector as a tag rather than a session ID.
+const tagPrefix = "#"
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// Tags returns the session's labels, sorted.
+func (s *Session) Tags() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.tags)
+}
+
+func (s *Session) setTags(tags []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tags = slices.Sorted(slices.Values(tags))
+}
+
+// Matches reports whether selector names this session: its ID, #tag for one
+// of its tags, or "all".
+func (s *Session) Matches(selector string) bool {
+	if selector == "all" || selector == s.ID {
+		return true
+	}
+	tag, ok := strings.CutPrefix(selector, tagPrefix)
+	return ok && slices.Contains(s.Tags(), tag)
+}
+
+// loadSessionTags copies the stored tags onto the configured sessions.
+func loadSessionTags(st store.Store, sessions []*Session) error {
+	tags, err := st.SessionTags()
+	if err != nil {
+		return err
+	}
+	for _, sess := range sessions {
+		sess.setTags(tags[sess.ID])
+	}
+	return nil
+}
+
+// normalizeSelector lowercases tag selectors; session IDs are hex already.
+func normalizeSelector(sel string) string {
+	return strings.ToLower(sel)
+}
+
+// selectSessions resolves selectors to the sessions they name, in
+// configuration order. Selectors that match no session are returned as
+// unknown, so typos are reported instead of silently selecting nothing.
+func selectSessions(sessions []*Session, selectors []string) ([]*Session, []string) {
+	var unknown []string
+	picked := make(map[*Session]bool)
+	for _, sel := range selectors {
+		found := false
+		for _, sess := range sessions {
+			if sess.Matches(sel) {
+				picked[sess] = true
+				found = true
+			}
+		}
+		if !found {
+			unknown = append(unknown, sel)
+		}
+	}
+	var out []*Session
+	for _, sess := range sessions {
+		if picked[sess] {
+			out = append(out, sess)
+		}
+	}
+	return out, unknown
+}
+
+// formatSessionGroups lists sessions under each of their tags; a session with
+// several tags shows up once per tag.
+func formatSessionGroups(sessions []*Session) string {
+	groups := make(map[string][]string)
+	var untagged []string
+	for _, sess := range sessions {
+		line := formatSession(sess)
+		tags := sess.Tags()
+		if len(tags) == 0 {
+			untagged = append(untagged, line)
+		}
+		for _, tag := range tags {
+			groups[tag] = append(groups[tag], line)
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "🔌 <b>Sessions (%d)</b>", len(sessions))
+	for _, tag := range slices.Sorted(maps.Keys(groups)) {
+		fmt.Fprintf(&b, "\n\n🏷 <b>%s</b> (%d)\n%s", html.EscapeString(tagPrefix+tag), len(groups[tag]), strings.Join(groups[tag], "\n"))
+	}
+	if len(untagged) > 0 {
+		if len(groups) > 0 {
+			fmt.Fprintf(&b, "\n\n<b>untagged</b> (%d)", len(untagged))
+		}
+		b.WriteString("\n" + strings.Join(untagged, "\n"))
+	}
+	return b.String()
+}
+
+func registerTagCommands(client *telegram.Client, st store.Store, sessions []*Session, a *access, au *auditor) {
+	client.On("cmd:tag", a.require(store.RoleAdmin, func(m *telegram.NewMessage) error {
+		const usage = "Usage: /tag &lt;session|#tag|all&gt; &lt;tag…&gt;\nTags are lowercase letters, digits, - and _."
+		args := strings.Fields(strings.ToLower(m.Args()))
+		if len(args) < 2 {
+			_, _ = m.Reply(usage)
+			return nil
+		}
+		tags := make([]string, 0, len(args)-1)
+		for _, t := range args[1:] {
+			t = strings.TrimPrefix(t, tagPrefix)
+			if !tagPattern.MatchString(t) {
+				_, _ = m.Reply("❌ Invalid tag: " + html.EscapeString(t) + "\n" + usage)
+				return nil
+			}
+			tags = append(tags, t)
+		}
+		return changeTags(m, st, sessions, au, "tag", args[0], func(sess *Session) error {
+			return st.TagSession(sess.ID, tags)
+		})
+	}))
+
+	client.On("cmd:untag", a.require(store.RoleAdmin, func(m *telegram.NewMessage) error {
+		args := strings.Fields(strings.ToLower(m.Args()))
+		if len(args) == 0 {
+			_, _ = m.Reply("Usage: /untag &lt;session|#tag|all&gt; [tag…]\nWithout tags, every tag is removed.")
+			return nil
+		}
+		tags := make([]string, 0, len(args)-1)
+		for _, t := range args[1:] {
+			tags = append(tags, strings.TrimPrefix(t, tagPrefix))
+		}
+		return changeTags(m, st, sessions, au, "untag", args[0], func(sess *Session) error {
+			return st.UntagSession(sess.ID, tags)
+		})
+	}))
+}
+
+// changeTags applies change to every session selector names, then reloads
+// the tags of all sessions so selectors see the new state at once.
+func changeTags(m *telegram.NewMessage, st store.Store, sessions []*Session, au *auditor, command, selector string, change func(*Session) error) error {
+	picked, unknown := selectSessions(sessions, []string{selector})
+	if len(unknown) > 0 {
+		_, _ = m.Reply("❌ No session matches " + html.EscapeString(selector) + ". See /sessions.")
+		return nil
+	}
+	before := tagState(picked)
+	for _, sess := range picked {
+		if err := change(sess); err != nil {
+			_, _ = m.Reply("❌ Failed to update tags: " + err.Error())
+			return err
+		}
+	}
+	if err := loadSessionTags(st, sessions); err != nil {
+		_, _ = m.Reply("❌ Failed to reload tags: " + err.Error())
+		return err
+	}
+	after := tagState(picked)
+	au.recordMsg(m, command, before, after)
+	_, _ = m.Reply("✅ " + html.EscapeString(after))
+	return nil
+}
+
+func tagState(sessions []*Session) string {
+	parts := make([]string, len(sessions))
+	for i, sess := range sessions {
+		tags := sess.Tags()
+		if len(tags) == 0 {
+			parts[i] = sess.ID + ": no tags"
+			continue
+		}
+		parts[i] = sess.ID + ": " + tagPrefix + strings.Join(tags, " "+tagPrefix)
+	}
+	return strings.Join(parts, "; ")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/amarnathcjd/gogram/telegram"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are never written out.
+var sensitiveKeys = map[string]struct{}{
+	"token":          {},
+	"bot_token":      {},
+	"app_hash":       {},
+	"session_string": {},
+	"password":       {},
+}
+
+type logConfig struct {
+	Level       slog.Level
+	JSON        bool
+	GogramLevel telegram.LogLevel
+}
+
+var gogramLog = logConfig{GogramLevel: telegram.LogInfo}
+
+func parseLogConfig(level, format, gogramLevel string) (logConfig, error) {
+	var cfg logConfig
+	if err := cfg.Level.UnmarshalText([]byte(orDefault(level, "info"))); err != nil {
+		return cfg, fmt.Errorf("LOG_LEVEL: %w", err)
+	}
+	switch strings.ToLower(orDefault(format, "text")) {
+	case "text":
+	case "json":
+		cfg.JSON = true
+	default:
+		return cfg, fmt.Errorf("LOG_FORMAT must be json or text, got %q", format)
+	}
+	lvl, err := parseGogramLevel(orDefault(gogramLevel, "info"))
+	if err != nil {
+		return cfg, fmt.Errorf("GOGRAM_LOG_LEVEL: %w", err)
+	}
+	cfg.GogramLevel = lvl
+	return cfg, nil
+}
+
+func parseGogramLevel(s string) (telegram.LogLevel, error) {
+	switch strings.ToLower(s) {
+	case "trace":
+		return telegram.LogTrace, nil
+	case "debug":
+		return telegram.LogDebug, nil
+	case "info":
+		return telegram.LogInfo, nil
+	case "warn", "warning":
+		return telegram.LogWarn, nil
+	case "error":
+		return telegram.LogError, nil
+	case "off", "none", "disable":
+		return telegram.LogDisable, nil
+	}
+	return 0, fmt.Errorf("unknown level %q", s)
+}
+
+// setupLogging installs the process-wide slog logger. Every occurrence of a
+// secret in a message or attribute value is replaced before it is written.
+func setupLogging(w io.Writer, cfg logConfig, secrets []string) {
+	opts := &slog.HandlerOptions{Level: cfg.Level}
+	var h slog.Handler
+	if cfg.JSON {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	slog.SetDefault(slog.New(newRedactHandler(h, secrets)))
+	gogramLog = cfg
+}
+
+// gogramLogger returns a fresh logger for a gogram client; gogram mutates the
+// prefix of the logger it is given, so clients must not share one.
+func gogramLogger() telegram.Logger {
+	return telegram.NewDefaultLogger("gogram").
+		SetLevel(gogramLog.GogramLevel).
+		SetJSONMode(gogramLog.JSON)
+}
+
+type redactHandler struct {
+	slog.Handler
+	replacer *strings.Replacer
+}
+
+func newRedactHandler(h slog.Handler, secrets []string) slog.Handler {
+	var pairs []string
+	for _, s := range secrets {
+		if s != "" {
+			pairs = append(pairs, s, redacted)
+		}
+	}
+	return &redactHandler{Handler: h, replacer: strings.NewReplacer(pairs...)}
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	out := slog.NewRecord(r.Time, r.Level, h.replacer.Replace(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		out.AddAttrs(h.redact(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, out)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clean := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		clean[i] = h.redact(a)
+	}
+	return &redactHandler{Handler: h.Handler.WithAttrs(clean), replacer: h.replacer}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{Handler: h.Handler.WithGroup(name), replacer: h.replacer}
+}
+
+func (h *redactHandler) redact(a slog.Attr) slog.Attr {
+	if _, ok := sensitiveKeys[strings.ToLower(a.Key)]; ok {
+		return slog.String(a.Key, redacted)
+	}
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, h.replacer.Replace(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		clean := make([]any, len(group))
+		for i, ga := range group {
+			clean[i] = h.redact(ga)
+		}
+		return slog.Group(a.Key, clean...)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return slog.String(a.Key, h.replacer.Replace(err.Error()))
+		}
+	}
+	return a
+}
+
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+func orDefault(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
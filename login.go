@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/amarnathcjd/gogram/telegram"
+	"gopkg.in/yaml.v3"
+)
+
+func cmdLogin(args []string) int {
+	fs := newFlagSet("login")
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to the YAML config file (for app_id/app_hash)")
+	phone := fs.String("phone", "", "phone number in international format; prompted for when empty")
+	save := fs.String("save", "", "append the new session to this YAML config file instead of printing it")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		return failure("loading configuration", err)
+	}
+	if cfg.AppID <= 0 || cfg.AppHash == "" {
+		return usageError("app_id and app_hash (APP_ID/APP_HASH) are required to log in")
+	}
+	if cfg.Log.GogramLevel == "" {
+		cfg.Log.GogramLevel = "warn"
+	}
+	logCfg, err := parseLogConfig(cfg.Log.Level, cfg.Log.Format, cfg.Log.GogramLevel)
+	if err != nil {
+		return failure("parsing logging configuration", err)
+	}
+	setupLogging(os.Stderr, logCfg, cfg.Secrets())
+
+	in := bufio.NewReader(os.Stdin)
+	if *phone == "" {
+		if *phone, err = prompt(in, "Phone number (international format): "); err != nil {
+			return failure("reading phone number", err)
+		}
+	}
+
+	client, err := telegram.NewClient(telegram.ClientConfig{
+		AppID:         cfg.AppID,
+		AppHash:       cfg.AppHash,
+		MemorySession: true,
+		NoUpdates:     true,
+		Logger:        gogramLogger(),
+	})
+	if err != nil {
+		return failure("creating client", err)
+	}
+	defer client.Stop()
+
+	ok, err := client.Login(*phone, &telegram.LoginOptions{
+		CodeCallback:     func() (string, error) { return prompt(in, "Login code: ") },
+		PasswordCallback: func() (string, error) { return prompt(in, "2FA password: ") },
+	})
+	if err != nil {
+		return failure("logging in", err)
+	}
+	if !ok {
+		return failure("logging in", errors.New("authorization was not completed"))
+	}
+	me, err := client.GetMe()
+	if err != nil {
+		return failure("getting self user", err)
+	}
+
+	sess := client.ExportSession()
+	kind := "non-premium"
+	if me.Premium {
+		kind = "premium"
+	}
+	fmt.Printf("Logged in as %s (id=%d), %s account\nSession ID: %s\n", displayUser(me), me.ID, kind, sessionID(sess))
+
+	if *save != "" {
+		if err := appendSessionToConfig(*save, SessionConfig{Session: sess, Premium: me.Premium}); err != nil {
+			return failure("saving session to "+*save, err)
+		}
+		fmt.Printf("Session appended to %s as a %s session.\n", *save, kind)
+		return 0
+	}
+	env := "NPREM_SESSIONS"
+	if me.Premium {
+		env = "PREM_SESSIONS"
+	}
+	fmt.Printf("\nAdd this string to %s (keep it secret, it grants full account access):\n\n%s\n", env, sess)
+	return 0
+}
+
+func prompt(in *bufio.Reader, label string) (string, error) {
+	fmt.Print(label)
+	line, err := in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+func displayUser(u *telegram.UserObj) string {
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if u.Username != "" {
+		name += " @" + u.Username
+	}
+	return name
+}
+
+// appendSessionToConfig adds a session entry to the YAML file at path,
+// creating it if needed. It edits the document tree so existing comments and
+// ordering survive.
+func appendSessionToConfig(path string, sc SessionConfig) error {
+	var doc yaml.Node
+	raw, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			return fmt.Errorf("parsing %s: %w", path, err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: top level must be a mapping", path)
+	}
+
+	var entry yaml.Node
+	if err := entry.Encode(sc); err != nil {
+		return err
+	}
+	var list *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "sessions" {
+			list = root.Content[i+1]
+			break
+		}
+	}
+	if list == nil {
+		list = &yaml.Node{Kind: yaml.SequenceNode}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "sessions"}, list)
+	}
+	if list.Kind != yaml.SequenceNode {
+		if list.Tag != "!!null" {
+			return fmt.Errorf("%s: sessions must be a list", path)
+		}
+		*list = yaml.Node{Kind: yaml.SequenceNode}
+	}
+	list.Content = append(list.Content, &entry)
+
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	return os.WriteFile(path, out.Bytes(), 0o600)
+}
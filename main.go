@@ -2,12 +2,18 @@ package main
 
 import (
 	"context"
-	"log"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
 	"os"
 	"os/signal"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/amarnathcjd/gogram/telegram"
 	"github.com/joho/godotenv"
@@ -15,88 +21,107 @@ import (
 	"github.com/sandeep97217890-droid/ReactionBot/store"
 )
 
+// storeCacheTTL bounds how long the reaction hot path can miss changes made
+// outside this process, by offline commands or other replicas.
+const storeCacheTTL = 10 * time.Second
+
 func main() {
 	_ = godotenv.Load()
 
-	appIDStr := mustEnv("APP_ID")
-	appHash := mustEnv("APP_HASH")
+	os.Exit(dispatch(os.Args[1:]))
+}
 
-	appID, err := strconv.ParseInt(appIDStr, 10, 32)
-	if err != nil {
-		log.Fatalf("APP_ID must be a valid integer: %v", err)
+func run(args []string) {
+	fs := newFlagSet("run")
+	configPath := fs.String("config", os.Getenv("CONFIG_FILE"), "path to the YAML config file")
+	if err := fs.Parse(args); err != nil {
+		os.Exit(2)
 	}
 
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "reactions.db"
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fatal("Failed to load configuration", "err", err)
+	}
+	logCfg, err := parseLogConfig(cfg.Log.Level, cfg.Log.Format, cfg.Log.GogramLevel)
+	if err != nil {
+		fatal("Invalid logging configuration", "err", err)
+	}
+	setupLogging(os.Stderr, logCfg, cfg.Secrets())
+	if err := cfg.Validate(); err != nil {
+		fatal("Invalid configuration", "err", err)
 	}
 
-	st, err := store.New(dbPath)
+	st, err := store.New(cfg.StoreDSN())
 	if err != nil {
-		log.Fatalf("Failed to open database: %v", err)
+		fatal("Failed to open database", "db", describeDSN(cfg.StoreDSN()), "err", err)
 	}
+	st = store.NewCached(st, storeCacheTTL)
 	defer st.Close()
-
-	premSessions := parseSessions(os.Getenv("PREM_SESSIONS"))
-	npremSessions := parseSessions(os.Getenv("NPREM_SESSIONS"))
-	botToken := os.Getenv("BOT_TOKEN")
-
-	if len(premSessions)+len(npremSessions) == 0 && botToken == "" {
-		log.Fatal("No sessions or bot token configured. Set PREM_SESSIONS, NPREM_SESSIONS and/or BOT_TOKEN.")
+	for _, t := range cfg.AllTenants() {
+		if err := st.ForTenant(t.ID).InitTenant(); err != nil {
+			fatal("Failed to initialise tenant", "tenant", t.ID, "err", err)
+		}
 	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	var background sync.WaitGroup
+	if cfg.Backup.Dir != "" {
+		interval, _ := cfg.Backup.IntervalDuration()
+		startBackups(ctx, &background, st, cfg.Backup.Dir, interval, cfg.Backup.Keep)
+	}
+
 	var clients []*telegram.Client
-	var userClients []*telegram.Client
-	var sessions []handlers.Session
+	var sessions []*handlers.Session
+	dialers := make(map[*handlers.Session]handlers.Dialer)
 	startedCount := 0
 
-	for _, sess := range premSessions {
-		if client := startSession(int32(appID), appHash, sess, true); client != nil {
-			sessions = append(sessions, handlers.Session{Client: client, IsPremium: true})
-			clients = append(clients, client)
-			userClients = append(userClients, client)
-			startedCount++
-		}
-	}
-	for _, sess := range npremSessions {
-		if client := startSession(int32(appID), appHash, sess, false); client != nil {
-			sessions = append(sessions, handlers.Session{Client: client, IsPremium: false})
-			clients = append(clients, client)
-			userClients = append(userClients, client)
+	for _, sc := range cfg.Sessions {
+		sess := handlers.NewSession(sessionID(sc.Session), sc.Premium)
+		sess.Tenant = sc.SessionTenant()
+		sess.Quota = cfg.SessionQuota(sc)
+		sessions = append(sessions, sess)
+		dialers[sess] = sessionDialer(cfg.AppID, cfg.AppHash, sc.Session)
+	}
+
+	reactor := handlers.Register(ctx, sessions, st, handlers.PoolConfig{Workers: cfg.Reactions.Workers, Queue: cfg.Reactions.Queue})
+	supervisor := handlers.NewSupervisor(st, reactor.Attach)
+	// Sessions outlive the signal so queued reactions can still be sent.
+	sessionCtx, stopSessions := context.WithCancel(context.Background())
+	defer stopSessions()
+	for _, sess := range sessions {
+		if supervisor.Start(sessionCtx, sess, dialers[sess]) {
 			startedCount++
 		}
 	}
 
-	if len(sessions) > 0 {
-		handlers.Register(sessions, st)
-	}
-
-	if botToken != "" {
-		ownerIDs := parseOwnerIDs(mustEnv("OWNER_IDS"))
-		if len(ownerIDs) == 0 {
-			log.Fatal("OWNER_IDS must contain at least one valid user ID when BOT_TOKEN is configured.")
-		}
+	if cfg.BotToken != "" {
 		client, err := telegram.NewClient(telegram.ClientConfig{
-			AppID:    int32(appID),
-			AppHash:  appHash,
-			LogLevel: telegram.LogInfo,
+			AppID:   cfg.AppID,
+			AppHash: cfg.AppHash,
+			Logger:  gogramLogger(),
 		})
 		if err != nil {
-			log.Printf("Failed to create bot client: %v", err)
-		} else if err := client.LoginBot(botToken); err != nil {
-			log.Printf("Failed to login bot: %v", err)
+			slog.Error("Failed to create bot client", "err", err)
+		} else if err := client.LoginBot(cfg.BotToken); err != nil {
+			slog.Error("Failed to login bot", "err", err)
 			_ = client.Disconnect()
 		} else {
 			me, err := client.GetMe()
 			if err != nil {
-				log.Printf("Failed to get bot user: %v", err)
+				slog.Error("Failed to get bot user", "err", err)
 				_ = client.Disconnect()
 			} else {
-				log.Printf("Bot logged in as: @%s (id=%d)", me.Username, me.ID)
-				handlers.RegisterBot(client, st, ownerIDs, userClients)
+				slog.Info("Bot logged in", "username", me.Username, "user_id", me.ID)
+				var tenants []handlers.Tenant
+				for _, t := range cfg.AllTenants() {
+					tenants = append(tenants, handlers.Tenant{ID: t.ID, OwnerIDs: t.Owners, AuditChatID: t.AuditChatID})
+				}
+				chatDefaults, _ := cfg.ChatDefaults.Resolve()
+				reactor.SetEvents(handlers.RegisterBot(client, st, sessions, handlers.BotConfig{
+					Tenants: tenants, Storage: reactor.Health(), Queue: reactor.Queue(), Reactor: reactor, ChatDefaults: chatDefaults,
+				}))
 				clients = append(clients, client)
 				startedCount++
 			}
@@ -104,46 +129,59 @@ func main() {
 	}
 
 	if startedCount == 0 {
-		log.Fatal("All clients failed to start.")
+		fatal("All clients failed to start.")
 	}
 
 	<-ctx.Done()
 	for _, c := range clients {
 		_ = c.Stop()
 	}
+	grace, _ := cfg.Reactions.GraceDuration()
+	reactor.Shutdown(grace)
+	stopSessions()
+	supervisor.Wait()
+	background.Wait()
+}
+
+func sessionDialer(appID int32, appHash, sess string) handlers.Dialer {
+	return func() (*telegram.Client, *telegram.UserObj, error) {
+		return startSession(appID, appHash, sess)
+	}
 }
 
-func startSession(appID int32, appHash, sess string, isPremium bool) *telegram.Client {
+func startSession(appID int32, appHash, sess string) (*telegram.Client, *telegram.UserObj, error) {
 	client, err := telegram.NewClient(telegram.ClientConfig{
 		AppID:         appID,
 		AppHash:       appHash,
 		StringSession: sess,
 		MemorySession: true,
-		LogLevel:      telegram.LogInfo,
+		Logger:        gogramLogger(),
 	})
 	if err != nil {
-		log.Printf("Failed to create client: %v", err)
-		return nil
+		return nil, nil, fmt.Errorf("creating client: %w", err)
 	}
 	authorized, err := client.IsAuthorized()
 	if err != nil {
-		log.Printf("Session authorization check failed (%v), skipping", err)
-		_ = client.Disconnect()
-		return nil
+		_ = client.Stop()
+		return nil, nil, fmt.Errorf("checking authorization: %w", err)
 	}
 	if !authorized {
-		log.Printf("Session not authorized, skipping")
-		_ = client.Disconnect()
-		return nil
+		_ = client.Stop()
+		return nil, nil, errors.New("session not authorized")
 	}
 	me, err := client.GetMe()
 	if err != nil {
-		log.Printf("Failed to get self user: %v", err)
-		_ = client.Disconnect()
-		return nil
+		_ = client.Stop()
+		return nil, nil, fmt.Errorf("getting self user: %w", err)
 	}
-	log.Printf("Logged in as: %s %s (id=%d, premium=%v)", me.FirstName, me.LastName, me.ID, isPremium)
-	return client
+	return client, me, nil
+}
+
+// sessionID derives a stable, non-secret identifier for a session string so
+// it can be tracked in the store and referred to in commands.
+func sessionID(sess string) string {
+	sum := sha256.Sum256([]byte(sess))
+	return hex.EncodeToString(sum[:])[:12]
 }
 
 func parseSessions(raw string) []string {
@@ -162,7 +200,7 @@ func parseSessions(raw string) []string {
 	return out
 }
 
-func parseOwnerIDs(raw string) []int64 {
+func parseOwnerIDs(raw string) ([]int64, error) {
 	var ids []int64
 	for _, p := range strings.Split(raw, ",") {
 		p = strings.TrimSpace(p)
@@ -171,18 +209,9 @@ func parseOwnerIDs(raw string) []int64 {
 		}
 		id, err := strconv.ParseInt(p, 10, 64)
 		if err != nil {
-			log.Printf("Skipping invalid owner ID %q: %v", p, err)
-			continue
+			return nil, fmt.Errorf("invalid owner ID %q: %w", p, err)
 		}
 		ids = append(ids, id)
 	}
-	return ids
-}
-
-func mustEnv(key string) string {
-	v := os.Getenv(key)
-	if v == "" {
-		log.Fatalf("Required environment variable %s is not set", key)
-	}
-	return v
+	return ids, nil
 }
@@ -0,0 +1,34 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AlbumMode is which messages of a media album get reactions. Telegram
+// delivers an album as one message per item, sharing a grouped ID.
+type AlbumMode string
+
+const (
+	AlbumFirst   AlbumMode = "first"   // the first item only
+	AlbumCaption AlbumMode = "caption" // the item carrying the caption, else the first
+	AlbumAll     AlbumMode = "all"     // every item
+)
+
+// DefaultAlbumMode reacts to an album once, like to any other post.
+const DefaultAlbumMode = AlbumFirst
+
+func ParseAlbumMode(s string) (AlbumMode, error) {
+	switch m := AlbumMode(strings.ToLower(s)); m {
+	case AlbumFirst, AlbumCaption, AlbumAll:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown album mode %q: use first, caption or all", s)
+}
+
+func (s *SQLStore) SetChatAlbums(chatID int64, mode AlbumMode) error {
+	if _, err := ParseAlbumMode(string(mode)); err != nil {
+		return err
+	}
+	return s.updateChat(chatID, `UPDATE chats SET albums = ? WHERE tenant_id = ? AND chat_id = ?`, string(mode))
+}
@@ -0,0 +1,72 @@
+package store
+
+// A chat with no assigned sessions is in "all members" mode: every session of
+// the tenant that is in the chat reacts. Assigning sessions limits reactions
+// to them. Assignments are stored as selectors, a session ID or #tag, so a
+// tag assignment also covers sessions tagged later.
+
+func (s *SQLStore) AssignSessions(chatID int64, sessionIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, id := range sessionIDs {
+		if _, err := tx.Exec(`INSERT INTO chat_sessions (tenant_id, chat_id, session_id) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`, s.tenant, chatID, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UnassignSessions removes the given sessions from a chat, or all of them
+// when sessionIDs is empty, which puts the chat back in all-members mode.
+func (s *SQLStore) UnassignSessions(chatID int64, sessionIDs []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(sessionIDs) == 0 {
+		_, err := s.db.Exec(`DELETE FROM chat_sessions WHERE tenant_id = ? AND chat_id = ?`, s.tenant, chatID)
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, id := range sessionIDs {
+		if _, err := tx.Exec(`DELETE FROM chat_sessions WHERE tenant_id = ? AND chat_id = ? AND session_id = ?`, s.tenant, chatID, id); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ChatSessions lists the sessions assigned to a chat; none means all members.
+func (s *SQLStore) ChatSessions(chatID int64) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return queryStrings(s.db, `SELECT session_id FROM chat_sessions WHERE tenant_id = ? AND chat_id = ? ORDER BY session_id`, s.tenant, chatID)
+}
+
+// AllChatSessions maps every chat with assigned sessions to their IDs.
+func (s *SQLStore) AllChatSessions() (map[int64][]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rows, err := s.db.Query(`SELECT chat_id, session_id FROM chat_sessions WHERE tenant_id = ? ORDER BY chat_id, session_id`, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[int64][]string)
+	for rows.Next() {
+		var chatID int64
+		var id string
+		if err := rows.Scan(&chatID, &id); err != nil {
+			return nil, err
+		}
+		out[chatID] = append(out[chatID], id)
+	}
+	return out, rows.Err()
+}
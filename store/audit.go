@@ -0,0 +1,55 @@
+package store
+
+import "time"
+
+type AuditEntry struct {
+	ID      int64
+	At      time.Time
+	UserID  int64
+	Command string
+	Args    string
+	Before  string
+	After   string
+}
+
+func (s *SQLStore) AddAudit(e AuditEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+	_, err := s.db.Exec(`INSERT INTO audit_log (tenant_id, at, user_id, command, args, before_value, after_value) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		s.tenant, e.At.Unix(), e.UserID, e.Command, e.Args, e.Before, e.After)
+	return err
+}
+
+// ListAudit returns up to limit entries, newest first, skipping offset.
+func (s *SQLStore) ListAudit(limit, offset int) ([]AuditEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rows, err := s.db.Query(`SELECT id, at, user_id, command, args, before_value, after_value FROM audit_log
+WHERE tenant_id = ? ORDER BY id DESC LIMIT ? OFFSET ?`, s.tenant, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		var at int64
+		if err := rows.Scan(&e.ID, &at, &e.UserID, &e.Command, &e.Args, &e.Before, &e.After); err != nil {
+			return nil, err
+		}
+		e.At = time.Unix(at, 0)
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLStore) CountAudit() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM audit_log WHERE tenant_id = ?`, s.tenant).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,342 @@
+package store
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cacheSnapshot is everything the reaction hot path reads, loaded in one go.
+type cacheSnapshot struct {
+	loaded   time.Time
+	enabled  bool
+	chats    map[int64]bool // chat ID → active
+	reactors map[int64]int
+	ids      []int64
+	list     []Chat
+	prem     []string
+	nprem    []string
+	cprem    []string // comment pools
+	cnprem   []string
+	byID     map[int64]Chat
+	// parents maps discussion groups to the channel whose comments they
+	// hold, for channels with comments on.
+	parents map[int64]int64
+	// assigned maps chats in assigned mode to their session selectors.
+	assigned map[int64][]string
+}
+
+// cachedStore serves settings, chats, session assignments and emoji pools
+// from memory. Writes made through it drop the snapshot and the next read
+// reloads it. Everything else goes straight to the wrapped Store.
+type cachedStore struct {
+	Store
+	ttl time.Duration
+	// views holds the cached view of every tenant, shared by all of them so
+	// ForTenant on any view returns the same cache.
+	views *sync.Map
+
+	// loadMu serialises loads with invalidations, so a load that read the
+	// database before a write can never be stored after that write's
+	// invalidation.
+	loadMu sync.Mutex
+	snap   atomic.Pointer[cacheSnapshot]
+}
+
+// NewCached wraps st with an in-memory read cache. Writes from other
+// processes sharing the database, such as Postgres replicas or offline CLI
+// commands, are only seen after ttl; ttl <= 0 keeps the snapshot until the
+// next write through this Store.
+func NewCached(st Store, ttl time.Duration) Store {
+	c := &cachedStore{Store: st, ttl: ttl, views: &sync.Map{}}
+	c.views.Store(st.Tenant(), c)
+	return c
+}
+
+func (c *cachedStore) ForTenant(tenant string) Store {
+	if v, ok := c.views.Load(tenant); ok {
+		return v.(*cachedStore)
+	}
+	v, _ := c.views.LoadOrStore(tenant, &cachedStore{Store: c.Store.ForTenant(tenant), ttl: c.ttl, views: c.views})
+	return v.(*cachedStore)
+}
+
+func (c *cachedStore) InitTenant() error {
+	defer c.invalidate()
+	return c.Store.InitTenant()
+}
+
+func (c *cachedStore) snapshot() (*cacheSnapshot, error) {
+	if s := c.snap.Load(); s != nil && c.fresh(s) {
+		return s, nil
+	}
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	if s := c.snap.Load(); s != nil && c.fresh(s) {
+		return s, nil
+	}
+	s, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	c.snap.Store(s)
+	return s, nil
+}
+
+func (c *cachedStore) fresh(s *cacheSnapshot) bool {
+	return c.ttl <= 0 || time.Since(s.loaded) < c.ttl
+}
+
+func (c *cachedStore) load() (*cacheSnapshot, error) {
+	s := &cacheSnapshot{loaded: time.Now()}
+	var err error
+	if s.enabled, err = c.Store.IsEnabled(); err != nil {
+		return nil, err
+	}
+	if s.list, err = c.Store.ListChats(); err != nil {
+		return nil, err
+	}
+	s.chats = make(map[int64]bool, len(s.list))
+	s.reactors = make(map[int64]int)
+	s.ids = make([]int64, len(s.list))
+	s.byID = make(map[int64]Chat, len(s.list))
+	s.parents = make(map[int64]int64)
+	for i, ch := range s.list {
+		s.chats[ch.ID] = ch.Enabled
+		if ch.Reactors > 0 {
+			s.reactors[ch.ID] = ch.Reactors
+		}
+		s.ids[i] = ch.ID
+		s.byID[ch.ID] = ch
+		// The list is ordered by ID, so the lowest wins, as in the store.
+		if _, taken := s.parents[ch.DiscussionID]; ch.Comments && ch.DiscussionID != 0 && !taken {
+			s.parents[ch.DiscussionID] = ch.ID
+		}
+	}
+	if s.prem, err = c.Store.GetPremEmojis(); err != nil {
+		return nil, err
+	}
+	if s.nprem, err = c.Store.GetNpremEmojis(); err != nil {
+		return nil, err
+	}
+	if s.cprem, err = c.Store.GetCommentEmojis(true); err != nil {
+		return nil, err
+	}
+	if s.cnprem, err = c.Store.GetCommentEmojis(false); err != nil {
+		return nil, err
+	}
+	if s.assigned, err = c.Store.AllChatSessions(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *cachedStore) invalidate() {
+	c.loadMu.Lock()
+	defer c.loadMu.Unlock()
+	c.snap.Store(nil)
+}
+
+func (c *cachedStore) IsEnabled() (bool, error) {
+	s, err := c.snapshot()
+	if err != nil {
+		return false, err
+	}
+	return s.enabled, nil
+}
+
+func (c *cachedStore) HasChat(chatID int64) (bool, error) {
+	s, err := c.snapshot()
+	if err != nil {
+		return false, err
+	}
+	_, ok := s.chats[chatID]
+	return ok, nil
+}
+
+func (c *cachedStore) IsChatActive(chatID int64) (bool, error) {
+	s, err := c.snapshot()
+	if err != nil {
+		return false, err
+	}
+	return s.chats[chatID], nil
+}
+
+// The slice getters return copies: callers such as sendReaction shuffle
+// them in place.
+
+func (c *cachedStore) GetChats() ([]int64, error) {
+	s, err := c.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.ids), nil
+}
+
+func (c *cachedStore) ListChats() ([]Chat, error) {
+	s, err := c.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.list), nil
+}
+
+func (c *cachedStore) GetPremEmojis() ([]string, error) {
+	s, err := c.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.prem), nil
+}
+
+func (c *cachedStore) GetNpremEmojis() ([]string, error) {
+	s, err := c.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.nprem), nil
+}
+
+func (c *cachedStore) GetCommentEmojis(premium bool) ([]string, error) {
+	s, err := c.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	if premium {
+		return slices.Clone(s.cprem), nil
+	}
+	return slices.Clone(s.cnprem), nil
+}
+
+func (c *cachedStore) GetChat(chatID int64) (Chat, bool, error) {
+	s, err := c.snapshot()
+	if err != nil {
+		return Chat{}, false, err
+	}
+	ch, ok := s.byID[chatID]
+	return ch, ok, nil
+}
+
+func (c *cachedStore) DiscussionParent(discussionID int64) (int64, bool, error) {
+	s, err := c.snapshot()
+	if err != nil {
+		return 0, false, err
+	}
+	id, ok := s.parents[discussionID]
+	return id, ok, nil
+}
+
+func (c *cachedStore) ChatReactors(chatID int64) (int, error) {
+	s, err := c.snapshot()
+	if err != nil {
+		return 0, err
+	}
+	return s.reactors[chatID], nil
+}
+
+func (c *cachedStore) ChatSessions(chatID int64) ([]string, error) {
+	s, err := c.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(s.assigned[chatID]), nil
+}
+
+func (c *cachedStore) SetEnabled(enabled bool) error {
+	defer c.invalidate()
+	return c.Store.SetEnabled(enabled)
+}
+
+func (c *cachedStore) SetChatEnabled(chatID int64, enabled bool) error {
+	defer c.invalidate()
+	return c.Store.SetChatEnabled(chatID, enabled)
+}
+
+func (c *cachedStore) AddChat(chatID int64, d ChatDefaults) error {
+	defer c.invalidate()
+	return c.Store.AddChat(chatID, d)
+}
+
+func (c *cachedStore) RemoveChat(chatID int64) error {
+	defer c.invalidate()
+	return c.Store.RemoveChat(chatID)
+}
+
+func (c *cachedStore) MigrateChat(from, to int64) ([]string, error) {
+	tenants, err := c.Store.MigrateChat(from, to)
+	for _, t := range tenants {
+		c.ForTenant(t).(*cachedStore).invalidate()
+	}
+	return tenants, err
+}
+
+func (c *cachedStore) SetChatReactors(chatID int64, n int) error {
+	defer c.invalidate()
+	return c.Store.SetChatReactors(chatID, n)
+}
+
+func (c *cachedStore) SetChatComments(chatID int64, on bool) error {
+	defer c.invalidate()
+	return c.Store.SetChatComments(chatID, on)
+}
+
+func (c *cachedStore) SetChatDiscussion(chatID, discussionID int64) error {
+	defer c.invalidate()
+	return c.Store.SetChatDiscussion(chatID, discussionID)
+}
+
+func (c *cachedStore) SetChatRule(chatID int64, kind Kind, r Rule) error {
+	defer c.invalidate()
+	return c.Store.SetChatRule(chatID, kind, r)
+}
+
+func (c *cachedStore) SetChatAlbums(chatID int64, mode AlbumMode) error {
+	defer c.invalidate()
+	return c.Store.SetChatAlbums(chatID, mode)
+}
+
+func (c *cachedStore) AddCommentEmoji(premium bool, emoji string) error {
+	defer c.invalidate()
+	return c.Store.AddCommentEmoji(premium, emoji)
+}
+
+func (c *cachedStore) RemoveCommentEmoji(premium bool, emoji string) error {
+	defer c.invalidate()
+	return c.Store.RemoveCommentEmoji(premium, emoji)
+}
+
+func (c *cachedStore) AssignSessions(chatID int64, sessionIDs []string) error {
+	defer c.invalidate()
+	return c.Store.AssignSessions(chatID, sessionIDs)
+}
+
+func (c *cachedStore) UnassignSessions(chatID int64, sessionIDs []string) error {
+	defer c.invalidate()
+	return c.Store.UnassignSessions(chatID, sessionIDs)
+}
+
+func (c *cachedStore) AddPremEmoji(emoji string) error {
+	defer c.invalidate()
+	return c.Store.AddPremEmoji(emoji)
+}
+
+func (c *cachedStore) AddNpremEmoji(emoji string) error {
+	defer c.invalidate()
+	return c.Store.AddNpremEmoji(emoji)
+}
+
+func (c *cachedStore) RemovePremEmoji(emoji string) error {
+	defer c.invalidate()
+	return c.Store.RemovePremEmoji(emoji)
+}
+
+func (c *cachedStore) RemoveNpremEmoji(emoji string) error {
+	defer c.invalidate()
+	return c.Store.RemoveNpremEmoji(emoji)
+}
+
+func (c *cachedStore) Import(snap *Snapshot, mode ImportMode, dryRun bool) ([]string, error) {
+	defer c.invalidate()
+	return c.Store.Import(snap, mode, dryRun)
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// benchSessions is how many sessions react to each message in the
+// benchmarks.
+const benchSessions = 50
+
+// reactToMessage makes the reads the bot makes for one message: the global
+// switch, the chat and its assigned sessions, then the emoji pool of each
+// session, trying the comment pool first as comments do.
+func reactToMessage(st Store, chatID int64, sessions int) error {
+	if _, err := st.IsEnabled(); err != nil {
+		return err
+	}
+	if _, _, err := st.GetChat(chatID); err != nil {
+		return err
+	}
+	if _, err := st.ChatSessions(chatID); err != nil {
+		return err
+	}
+	for i := range sessions {
+		premium := i%2 == 0
+		if _, err := st.GetCommentEmojis(premium); err != nil {
+			return err
+		}
+		pool := st.GetNpremEmojis
+		if premium {
+			pool = st.GetPremEmojis
+		}
+		if _, err := pool(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func benchmarkHotPath(b *testing.B, cached bool) {
+	st, err := New(b.TempDir() + "/reactions.db")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer st.Close()
+	if cached {
+		st = NewCached(st, 0)
+	}
+	const chatID = -1001
+	if err := st.AddChat(chatID, DefaultChatDefaults); err != nil {
+		b.Fatal(err)
+	}
+	ids := make([]string, benchSessions)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("s%d", i)
+	}
+	if err := st.AssignSessions(chatID, ids); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := reactToMessage(st, chatID, benchSessions); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHotPathUncached(b *testing.B) { benchmarkHotPath(b, false) }
+func BenchmarkHotPathCached(b *testing.B)   { benchmarkHotPath(b, true) }
+
+func TestCacheInvalidation(t *testing.T) {
+	raw := openSQLite(t)
+	// Without a TTL only writes through the cache refresh the snapshot.
+	st := NewCached(raw, 0)
+	other := st.ForTenant("acme")
+	check(t, other.InitTenant())
+	if st.ForTenant("acme") != other {
+		t.Error("ForTenant returned a second cache for the same tenant")
+	}
+
+	// Load both snapshots.
+	if has, err := st.HasChat(-1001); err != nil || has {
+		t.Fatalf("HasChat() = %v, %v on an empty store", has, err)
+	}
+	if has, _ := other.HasChat(-1001); has {
+		t.Fatal("HasChat() = true on an empty tenant")
+	}
+
+	// Writes around the cache are not seen...
+	check(t, raw.AddPremEmoji("🤯"))
+	if prem, _ := st.GetPremEmojis(); slices.Contains(prem, "🤯") {
+		t.Fatal("the snapshot was not cached")
+	}
+	// ...until a write through it drops the snapshot.
+	paused := DefaultChatDefaults
+	paused.Paused = true
+	check(t, st.AddChat(-1001, paused))
+	has, _ := st.HasChat(-1001)
+	if active, _ := st.IsChatActive(-1001); !has || active {
+		t.Error("a chat added through the cache is not seen as paused")
+	}
+	if prem, _ := st.GetPremEmojis(); !slices.Contains(prem, "🤯") {
+		t.Error("the snapshot was not reloaded after a write")
+	}
+	check(t, st.SetChatEnabled(-1001, true))
+	if active, _ := st.IsChatActive(-1001); !active {
+		t.Error("IsChatActive() = false after resuming the chat")
+	}
+	check(t, st.SetChatReactors(-1001, 2))
+	if n, err := st.ChatReactors(-1001); err != nil || n != 2 {
+		t.Errorf("ChatReactors() = %d, %v after writing through the cache", n, err)
+	}
+	rule := Rule{Probability: 30, Filter: FilterMedia}
+	check(t, st.SetChatRule(-1001, KindPost, rule))
+	if c, _, _ := st.GetChat(-1001); c.Post != rule {
+		t.Errorf("GetChat() = %+v after setting the post rule through the cache", c)
+	}
+	check(t, st.AddCommentEmoji(true, "💯"))
+	if c, _ := st.GetCommentEmojis(true); !reflect.DeepEqual(c, []string{"💯"}) {
+		t.Errorf("GetCommentEmojis() = %v after adding through the cache", c)
+	}
+	check(t, st.SetEnabled(false))
+	if on, _ := st.IsEnabled(); on {
+		t.Error("IsEnabled() = true after SetEnabled(false)")
+	}
+	check(t, st.RemoveNpremEmoji("🔥"))
+	if nprem, _ := st.GetNpremEmojis(); slices.Contains(nprem, "🔥") {
+		t.Error("a removed emoji is still in the cached pool")
+	}
+
+	// The other tenant sees none of it.
+	if has, _ := other.HasChat(-1001); has {
+		t.Error("a chat leaked into another tenant's cache")
+	}
+	if on, _ := other.IsEnabled(); !on {
+		t.Error("disabling one tenant disabled another's cache")
+	}
+	if prem, _ := other.GetPremEmojis(); slices.Contains(prem, "🤯") {
+		t.Error("an emoji leaked into another tenant's cache")
+	}
+
+	// Its own writes are seen at once, and leave the first tenant alone.
+	check(t, other.AddChat(-1001, paused))
+	if active, _ := other.IsChatActive(-1001); active {
+		t.Error("a chat added through the cache is not seen as paused")
+	}
+	if active, _ := st.IsChatActive(-1001); !active {
+		t.Error("pausing a chat in one tenant paused it in another")
+	}
+	check(t, other.AssignSessions(-1001, []string{"s1"}))
+	if s, _ := other.ChatSessions(-1001); !reflect.DeepEqual(s, []string{"s1"}) {
+		t.Errorf("ChatSessions() = %v after assigning through the cache", s)
+	}
+	if s, _ := st.ChatSessions(-1001); len(s) != 0 {
+		t.Errorf("ChatSessions() = %v in the tenant that assigned none", s)
+	}
+}
@@ -0,0 +1,176 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Kind tells channel posts from comments under them, which live in the
+// channel's linked discussion group and have their own rules and pools.
+type Kind string
+
+const (
+	KindPost    Kind = "post"
+	KindComment Kind = "comment"
+)
+
+// Filter picks the messages of a kind that get reactions.
+type Filter string
+
+const (
+	FilterAll   Filter = "all"
+	FilterText  Filter = "text"  // messages without media
+	FilterMedia Filter = "media" // photos, videos, files, stickers…
+)
+
+func ParseFilter(s string) (Filter, error) {
+	switch f := Filter(strings.ToLower(s)); f {
+	case FilterAll, FilterText, FilterMedia:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown filter %q: use all, text or media", s)
+}
+
+// Rule is how a chat reacts to one kind of message: to those passing
+// Filter, each with Probability percent chance.
+type Rule struct {
+	Probability int    `json:"probability" yaml:"probability"`
+	Filter      Filter `json:"filter" yaml:"filter"`
+}
+
+// DefaultRule reacts to every message.
+var DefaultRule = Rule{Probability: 100, Filter: FilterAll}
+
+func (r Rule) String() string {
+	return fmt.Sprintf("%d%%, %s", r.Probability, r.Filter)
+}
+
+// Validate checks that r can be stored.
+func (r Rule) Validate() error {
+	if r.Probability < 1 || r.Probability > 100 {
+		return fmt.Errorf("probability %d is not between 1 and 100", r.Probability)
+	}
+	_, err := ParseFilter(string(r.Filter))
+	return err
+}
+
+// Rule returns the chat's rule for kind.
+func (c Chat) Rule(kind Kind) Rule {
+	if kind == KindComment {
+		return c.Comment
+	}
+	return c.Post
+}
+
+// chatColumns are the chats columns scanChat reads, in order.
+const chatColumns = `chat_id, enabled, reactors, comments, discussion_id, post_probability, post_filter, comment_probability, comment_filter, albums`
+
+func scanChat(row interface{ Scan(...any) error }) (Chat, error) {
+	var c Chat
+	var enabled, comments int
+	err := row.Scan(&c.ID, &enabled, &c.Reactors, &comments, &c.DiscussionID,
+		&c.Post.Probability, &c.Post.Filter, &c.Comment.Probability, &c.Comment.Filter, &c.Albums)
+	c.Enabled = enabled == 1
+	c.Comments = comments == 1
+	return c, err
+}
+
+func (s *SQLStore) GetChat(chatID int64) (Chat, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, err := scanChat(s.db.QueryRow(`SELECT `+chatColumns+` FROM chats WHERE tenant_id = ? AND chat_id = ?`, s.tenant, chatID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Chat{}, false, nil
+	}
+	if err != nil {
+		return Chat{}, false, fmt.Errorf("looking up chat %d: %w", chatID, err)
+	}
+	return c, true, nil
+}
+
+// SetChatComments turns reacting to comments in the chat's discussion group
+// on or off. Turning it off forgets the discussion group, so turning it on
+// again looks it up afresh.
+func (s *SQLStore) SetChatComments(chatID int64, on bool) error {
+	return s.updateChat(chatID, `UPDATE chats SET comments = ?, discussion_id = 0 WHERE tenant_id = ? AND chat_id = ?`, boolToInt(on))
+}
+
+// SetChatDiscussion records the discussion group linked to a channel; 0
+// means none is known.
+func (s *SQLStore) SetChatDiscussion(chatID, discussionID int64) error {
+	return s.updateChat(chatID, `UPDATE chats SET discussion_id = ? WHERE tenant_id = ? AND chat_id = ?`, discussionID)
+}
+
+func (s *SQLStore) SetChatRule(chatID int64, kind Kind, r Rule) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	col := "post"
+	if kind == KindComment {
+		col = "comment"
+	}
+	return s.updateChat(chatID, `UPDATE chats SET `+col+`_probability = ?, `+col+`_filter = ? WHERE tenant_id = ? AND chat_id = ?`, r.Probability, string(r.Filter))
+}
+
+// DiscussionParent returns the monitored channel whose comments live in
+// discussionID, if one has comments turned on.
+func (s *SQLStore) DiscussionParent(discussionID int64) (int64, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var id int64
+	err := s.db.QueryRow(`SELECT chat_id FROM chats WHERE tenant_id = ? AND discussion_id = ? AND comments = 1 ORDER BY chat_id LIMIT 1`, s.tenant, discussionID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("looking up discussion %d: %w", discussionID, err)
+	}
+	return id, true, nil
+}
+
+// updateChat runs a single-chat UPDATE whose last two arguments are the
+// tenant and chat ID.
+func (s *SQLStore) updateChat(chatID int64, query string, args ...any) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, err := s.db.Exec(query, append(args, s.tenant, chatID)...)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrChatNotFound
+	}
+	return nil
+}
+
+// The comment pools are used for comments instead of the post pools while
+// they hold any emoji.
+
+func commentPool(premium bool) string {
+	if premium {
+		return "comment_prem_emojis"
+	}
+	return "comment_nprem_emojis"
+}
+
+func (s *SQLStore) AddCommentEmoji(premium bool, emoji string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`INSERT INTO `+commentPool(premium)+` (tenant_id, emoji) VALUES (?, ?) ON CONFLICT DO NOTHING`, s.tenant, emoji)
+	return err
+}
+
+func (s *SQLStore) RemoveCommentEmoji(premium bool, emoji string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`DELETE FROM `+commentPool(premium)+` WHERE tenant_id = ? AND emoji = ?`, s.tenant, emoji)
+	return err
+}
+
+func (s *SQLStore) GetCommentEmojis(premium bool) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.queryEmojis(`SELECT emoji FROM ` + commentPool(premium) + ` WHERE tenant_id = ?`)
+}
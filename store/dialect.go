@@ -0,0 +1,127 @@
+package store
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// dialect holds what differs between the SQL backends. Everything else is
+// written once in the portable subset both understand, including
+// INSERT … ON CONFLICT.
+type dialect struct {
+	name   string
+	driver string
+	// numbered rewrites ? placeholders to $1, $2, ….
+	numbered   bool
+	migrations []string
+	// prepare runs before migrations, outside any transaction.
+	prepare string
+	// lock runs first in every migration transaction so concurrent
+	// replicas migrate one at a time.
+	lock          string
+	readVersion   string
+	writeVersion  string
+	supportsFiles bool
+	// params are appended to the DSN as query parameters.
+	params string
+}
+
+func (d *dialect) dsn(dsn string) string {
+	if d.params == "" {
+		return dsn
+	}
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + d.params
+}
+
+var sqlite = &dialect{
+	name:   "sqlite",
+	driver: "sqlite",
+	// WAL lets readers run alongside the writer, and busy_timeout makes
+	// concurrent writers wait for the lock instead of failing with
+	// SQLITE_BUSY. Both are set per connection by the driver.
+	params:        "_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)",
+	migrations:    sqliteMigrations,
+	readVersion:   `PRAGMA user_version`,
+	writeVersion:  `PRAGMA user_version = %d`,
+	supportsFiles: true,
+}
+
+var postgres = &dialect{
+	name:         "postgres",
+	driver:       "pgx",
+	numbered:     true,
+	migrations:   postgresMigrations,
+	prepare:      `CREATE TABLE IF NOT EXISTS schema_version (version INTEGER NOT NULL)`,
+	lock:         `SELECT pg_advisory_xact_lock(7372657)`,
+	readVersion:  `SELECT COALESCE(MAX(version), 0) FROM schema_version`,
+	writeVersion: `DELETE FROM schema_version; INSERT INTO schema_version (version) VALUES (%d)`,
+}
+
+func (d *dialect) rebind(query string) string {
+	if !d.numbered || !strings.Contains(query, "?") {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// db and tx wrap their database/sql counterparts so every query goes through
+// the dialect's rebind.
+type db struct {
+	*sql.DB
+	dialect *dialect
+}
+
+func (d *db) Exec(query string, args ...any) (sql.Result, error) {
+	return d.DB.Exec(d.dialect.rebind(query), args...)
+}
+
+func (d *db) Query(query string, args ...any) (*sql.Rows, error) {
+	return d.DB.Query(d.dialect.rebind(query), args...)
+}
+
+func (d *db) QueryRow(query string, args ...any) *sql.Row {
+	return d.DB.QueryRow(d.dialect.rebind(query), args...)
+}
+
+func (d *db) Begin() (*tx, error) {
+	t, err := d.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &tx{Tx: t, dialect: d.dialect}, nil
+}
+
+type tx struct {
+	*sql.Tx
+	dialect *dialect
+}
+
+func (t *tx) Exec(query string, args ...any) (sql.Result, error) {
+	return t.Tx.Exec(t.dialect.rebind(query), args...)
+}
+
+func (t *tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return t.Tx.Query(t.dialect.rebind(query), args...)
+}
+
+func (t *tx) QueryRow(query string, args ...any) *sql.Row {
+	return t.Tx.QueryRow(t.dialect.rebind(query), args...)
+}
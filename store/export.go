@@ -0,0 +1,514 @@
+package store
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SnapshotVersion is bumped whenever the Snapshot layout changes in a way
+// older readers cannot ignore. Version 2 writes canonical chat IDs (see
+// ParseChatID); version 1 files may hold bare channel IDs.
+const SnapshotVersion = 2
+
+// Snapshot is a portable copy of the bot's configuration: the global switch,
+// monitored chats with their managers, assigned sessions and rules, both
+// emoji pools and granted roles.
+// Sessions and the audit log are tied to the host and are not included.
+type Snapshot struct {
+	Version     int            `json:"version" yaml:"version"`
+	ExportedAt  time.Time      `json:"exported_at" yaml:"exported_at"`
+	Enabled     bool           `json:"enabled" yaml:"enabled"`
+	Chats       []SnapshotChat `json:"chats" yaml:"chats"`
+	PremEmojis  []string       `json:"prem_emojis" yaml:"prem_emojis"`
+	NpremEmojis []string       `json:"nprem_emojis" yaml:"nprem_emojis"`
+	// The comment pools are empty unless comments use their own emojis.
+	CommentPremEmojis  []string       `json:"comment_prem_emojis,omitempty" yaml:"comment_prem_emojis,omitempty"`
+	CommentNpremEmojis []string       `json:"comment_nprem_emojis,omitempty" yaml:"comment_nprem_emojis,omitempty"`
+	Users              []SnapshotUser `json:"users" yaml:"users"`
+}
+
+// Marshal encodes the snapshot as "json" or "yaml".
+func (snap *Snapshot) Marshal(format string) ([]byte, error) {
+	switch format {
+	case "json":
+		return json.MarshalIndent(snap, "", "  ")
+	case "yaml":
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(snap); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), enc.Close()
+	}
+	return nil, fmt.Errorf("unknown format %q: use json or yaml", format)
+}
+
+// ParseSnapshot decodes a JSON or YAML snapshot. JSON is valid YAML, so one
+// strict YAML decoder handles both and rejects unknown fields.
+func ParseSnapshot(data []byte) (*Snapshot, error) {
+	var snap Snapshot
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&snap); err != nil {
+		return nil, fmt.Errorf("parsing snapshot: %w", err)
+	}
+	if snap.Version == 0 {
+		return nil, errors.New("parsing snapshot: missing version")
+	}
+	if snap.Version < 2 {
+		for i := range snap.Chats {
+			snap.Chats[i].ID = LegacyChatID(snap.Chats[i].ID)
+		}
+	}
+	for _, c := range snap.Chats {
+		if _, err := ParseAlbumMode(string(c.albums())); err != nil {
+			return nil, fmt.Errorf("parsing snapshot: chat %d: %w", c.ID, err)
+		}
+		for _, kind := range []Kind{KindPost, KindComment} {
+			if err := c.rule(kind).Validate(); err != nil {
+				return nil, fmt.Errorf("parsing snapshot: chat %d %s rule: %w", c.ID, kind, err)
+			}
+		}
+	}
+	return &snap, nil
+}
+
+// SnapshotChat leaves out the discussion group, which is looked up again,
+// rules equal to DefaultRule and the default album mode.
+type SnapshotChat struct {
+	ID       int64     `json:"id" yaml:"id"`
+	Enabled  bool      `json:"enabled" yaml:"enabled"`
+	Reactors int       `json:"reactors,omitempty" yaml:"reactors,omitempty"`
+	Comments bool      `json:"comments,omitempty" yaml:"comments,omitempty"`
+	Post     *Rule     `json:"post,omitempty" yaml:"post,omitempty"`
+	Comment  *Rule     `json:"comment,omitempty" yaml:"comment,omitempty"`
+	Albums   AlbumMode `json:"albums,omitempty" yaml:"albums,omitempty"`
+	Managers []int64   `json:"managers,omitempty" yaml:"managers,omitempty"`
+	// Sessions holds the assigned session IDs and #tags; empty means every
+	// member session reacts.
+	Sessions []string `json:"sessions,omitempty" yaml:"sessions,omitempty"`
+}
+
+// rule returns the snapshot's rule for kind, filling in the default.
+func (c SnapshotChat) rule(kind Kind) Rule {
+	r := c.Post
+	if kind == KindComment {
+		r = c.Comment
+	}
+	if r == nil {
+		return DefaultRule
+	}
+	return *r
+}
+
+func (c SnapshotChat) albums() AlbumMode {
+	if c.Albums == "" {
+		return DefaultAlbumMode
+	}
+	return c.Albums
+}
+
+func snapshotRule(r Rule) *Rule {
+	if r == DefaultRule {
+		return nil
+	}
+	return &r
+}
+
+type SnapshotUser struct {
+	ID        int64 `json:"id" yaml:"id"`
+	Role      Role  `json:"role" yaml:"role"`
+	GrantedBy int64 `json:"granted_by,omitempty" yaml:"granted_by,omitempty"`
+}
+
+type ImportMode int
+
+const (
+	// ImportMerge adds and updates entries from the snapshot and keeps
+	// everything else.
+	ImportMerge ImportMode = iota
+	// ImportReplace makes the database match the snapshot exactly.
+	ImportReplace
+)
+
+func ParseImportMode(s string) (ImportMode, error) {
+	switch s {
+	case "merge":
+		return ImportMerge, nil
+	case "replace":
+		return ImportReplace, nil
+	}
+	return 0, fmt.Errorf("unknown import mode %q: use merge or replace", s)
+}
+
+// querier is the read side shared by *sql.DB and *sql.Tx.
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (s *SQLStore) Export() (*Snapshot, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return readSnapshot(s.db, s.tenant)
+}
+
+// Import applies snap in one transaction and returns the changes it made,
+// one human-readable line each. With dryRun the transaction is rolled back,
+// so the result is only a preview.
+func (s *SQLStore) Import(snap *Snapshot, mode ImportMode, dryRun bool) ([]string, error) {
+	if snap.Version > SnapshotVersion {
+		return nil, fmt.Errorf("snapshot version %d is newer than supported version %d", snap.Version, SnapshotVersion)
+	}
+	for _, u := range snap.Users {
+		if _, err := ParseRole(string(u.Role)); err != nil {
+			return nil, fmt.Errorf("user %d: %w", u.ID, err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	cur, err := readSnapshot(tx, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	im := importer{tx: tx, tenant: s.tenant}
+	im.apply(cur, snap, mode == ImportReplace)
+	if im.err != nil {
+		return nil, im.err
+	}
+	if dryRun {
+		return im.changes, nil
+	}
+	return im.changes, tx.Commit()
+}
+
+func readSnapshot(q querier, tenant string) (*Snapshot, error) {
+	snap := &Snapshot{Version: SnapshotVersion, ExportedAt: time.Now().UTC()}
+	var enabled string
+	err := q.QueryRow(`SELECT value FROM settings WHERE tenant_id = ? AND key = 'enabled'`, tenant).Scan(&enabled)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	snap.Enabled = enabled == "1"
+
+	rows, err := q.Query(`SELECT `+chatColumns+` FROM chats WHERE tenant_id = ? ORDER BY chat_id`, tenant)
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[int64]int)
+	for rows.Next() {
+		ch, err := scanChat(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[ch.ID] = len(snap.Chats)
+		c := SnapshotChat{
+			ID:       ch.ID,
+			Enabled:  ch.Enabled,
+			Reactors: ch.Reactors,
+			Comments: ch.Comments,
+			Post:     snapshotRule(ch.Post),
+			Comment:  snapshotRule(ch.Comment),
+		}
+		if ch.Albums != DefaultAlbumMode {
+			c.Albums = ch.Albums
+		}
+		snap.Chats = append(snap.Chats, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.Query(`SELECT chat_id, user_id FROM chat_managers WHERE tenant_id = ? ORDER BY chat_id, user_id`, tenant)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var chatID, userID int64
+		if err := rows.Scan(&chatID, &userID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if i, ok := index[chatID]; ok {
+			snap.Chats[i].Managers = append(snap.Chats[i].Managers, userID)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.Query(`SELECT chat_id, session_id FROM chat_sessions WHERE tenant_id = ? ORDER BY chat_id, session_id`, tenant)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var chatID int64
+		var id string
+		if err := rows.Scan(&chatID, &id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if i, ok := index[chatID]; ok {
+			snap.Chats[i].Sessions = append(snap.Chats[i].Sessions, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if snap.PremEmojis, err = queryStrings(q, `SELECT emoji FROM prem_emojis WHERE tenant_id = ? ORDER BY emoji`, tenant); err != nil {
+		return nil, err
+	}
+	if snap.NpremEmojis, err = queryStrings(q, `SELECT emoji FROM nprem_emojis WHERE tenant_id = ? ORDER BY emoji`, tenant); err != nil {
+		return nil, err
+	}
+	if snap.CommentPremEmojis, err = queryStrings(q, `SELECT emoji FROM comment_prem_emojis WHERE tenant_id = ? ORDER BY emoji`, tenant); err != nil {
+		return nil, err
+	}
+	if snap.CommentNpremEmojis, err = queryStrings(q, `SELECT emoji FROM comment_nprem_emojis WHERE tenant_id = ? ORDER BY emoji`, tenant); err != nil {
+		return nil, err
+	}
+
+	rows, err = q.Query(`SELECT user_id, role, granted_by FROM users WHERE tenant_id = ? ORDER BY user_id`, tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var u SnapshotUser
+		var role string
+		if err := rows.Scan(&u.ID, &role, &u.GrantedBy); err != nil {
+			return nil, err
+		}
+		u.Role = Role(role)
+		snap.Users = append(snap.Users, u)
+	}
+	return snap, rows.Err()
+}
+
+func queryStrings(q querier, query string, args ...any) ([]string, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// importer runs the statements for one import and collects a line per change.
+// The first error stops every later statement.
+type importer struct {
+	tx      *tx
+	tenant  string
+	changes []string
+	err     error
+}
+
+func (im *importer) exec(change, query string, args ...any) {
+	im.execN(change, query, args...)
+}
+
+// execN is exec that also returns the number of rows affected.
+func (im *importer) execN(change, query string, args ...any) int64 {
+	if im.err != nil {
+		return 0
+	}
+	res, err := im.tx.Exec(query, args...)
+	if err != nil {
+		im.err = fmt.Errorf("%s: %w", change, err)
+		return 0
+	}
+	im.changes = append(im.changes, change)
+	n, _ := res.RowsAffected()
+	return n
+}
+
+func (im *importer) apply(cur, want *Snapshot, replace bool) {
+	if cur.Enabled != want.Enabled {
+		im.exec(fmt.Sprintf("~ auto-reactions: %s → %s", onOff(cur.Enabled), onOff(want.Enabled)),
+			`INSERT INTO settings (tenant_id, key, value) VALUES (?, 'enabled', ?) ON CONFLICT(tenant_id, key) DO UPDATE SET value = excluded.value`,
+			im.tenant, strconv.Itoa(boolToInt(want.Enabled)))
+	}
+
+	have := make(map[int64]SnapshotChat, len(cur.Chats))
+	for _, c := range cur.Chats {
+		have[c.ID] = c
+	}
+	wanted := make(map[int64]bool, len(want.Chats))
+	for _, c := range want.Chats {
+		wanted[c.ID] = true
+		old, ok := have[c.ID]
+		switch {
+		case !ok:
+			post, comment := c.rule(KindPost), c.rule(KindComment)
+			im.exec(fmt.Sprintf("+ chat %d (%s)", c.ID, chatLabel(c.Enabled)),
+				`INSERT INTO chats (tenant_id, chat_id, enabled, reactors, comments, post_probability, post_filter, comment_probability, comment_filter, albums) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				im.tenant, c.ID, boolToInt(c.Enabled), c.Reactors, boolToInt(c.Comments), post.Probability, string(post.Filter), comment.Probability, string(comment.Filter), string(c.albums()))
+		default:
+			if old.Enabled != c.Enabled {
+				im.exec(fmt.Sprintf("~ chat %d: %s → %s", c.ID, chatLabel(old.Enabled), chatLabel(c.Enabled)),
+					`UPDATE chats SET enabled = ? WHERE tenant_id = ? AND chat_id = ?`, boolToInt(c.Enabled), im.tenant, c.ID)
+			}
+			if old.Reactors != c.Reactors {
+				im.exec(fmt.Sprintf("~ chat %d reactors: %s → %s", c.ID, reactorsLabel(old.Reactors), reactorsLabel(c.Reactors)),
+					`UPDATE chats SET reactors = ? WHERE tenant_id = ? AND chat_id = ?`, c.Reactors, im.tenant, c.ID)
+			}
+			if old.Comments != c.Comments {
+				im.exec(fmt.Sprintf("~ chat %d comments: %s → %s", c.ID, onOff(old.Comments), onOff(c.Comments)),
+					`UPDATE chats SET comments = ?, discussion_id = 0 WHERE tenant_id = ? AND chat_id = ?`, boolToInt(c.Comments), im.tenant, c.ID)
+			}
+			if old.albums() != c.albums() {
+				im.exec(fmt.Sprintf("~ chat %d albums: %s → %s", c.ID, old.albums(), c.albums()),
+					`UPDATE chats SET albums = ? WHERE tenant_id = ? AND chat_id = ?`, string(c.albums()), im.tenant, c.ID)
+			}
+			for _, kind := range []Kind{KindPost, KindComment} {
+				if before, after := old.rule(kind), c.rule(kind); before != after {
+					im.exec(fmt.Sprintf("~ chat %d %s rule: %s → %s", c.ID, kind, before, after),
+						`UPDATE chats SET `+string(kind)+`_probability = ?, `+string(kind)+`_filter = ? WHERE tenant_id = ? AND chat_id = ?`,
+						after.Probability, string(after.Filter), im.tenant, c.ID)
+				}
+			}
+		}
+		for _, u := range c.Managers {
+			if !slices.Contains(old.Managers, u) {
+				im.exec(fmt.Sprintf("+ manager %d of chat %d", u, c.ID),
+					`INSERT INTO chat_managers (tenant_id, chat_id, user_id) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`, im.tenant, c.ID, u)
+			}
+		}
+		for _, id := range c.Sessions {
+			if !slices.Contains(old.Sessions, id) {
+				im.exec(fmt.Sprintf("+ session %s assigned to chat %d", id, c.ID),
+					`INSERT INTO chat_sessions (tenant_id, chat_id, session_id) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`, im.tenant, c.ID, id)
+			}
+		}
+		if replace {
+			for _, u := range old.Managers {
+				if !slices.Contains(c.Managers, u) {
+					im.exec(fmt.Sprintf("- manager %d of chat %d", u, c.ID),
+						`DELETE FROM chat_managers WHERE tenant_id = ? AND chat_id = ? AND user_id = ?`, im.tenant, c.ID, u)
+				}
+			}
+			for _, id := range old.Sessions {
+				if !slices.Contains(c.Sessions, id) {
+					im.exec(fmt.Sprintf("- session %s assigned to chat %d", id, c.ID),
+						`DELETE FROM chat_sessions WHERE tenant_id = ? AND chat_id = ? AND session_id = ?`, im.tenant, c.ID, id)
+				}
+			}
+		}
+	}
+	if replace {
+		for _, c := range cur.Chats {
+			if !wanted[c.ID] {
+				for _, u := range c.Managers {
+					im.exec(fmt.Sprintf("- manager %d of chat %d", u, c.ID),
+						`DELETE FROM chat_managers WHERE tenant_id = ? AND chat_id = ? AND user_id = ?`, im.tenant, c.ID, u)
+				}
+				for _, id := range c.Sessions {
+					im.exec(fmt.Sprintf("- session %s assigned to chat %d", id, c.ID),
+						`DELETE FROM chat_sessions WHERE tenant_id = ? AND chat_id = ? AND session_id = ?`, im.tenant, c.ID, id)
+				}
+				im.exec(fmt.Sprintf("- chat %d", c.ID), `DELETE FROM chats WHERE tenant_id = ? AND chat_id = ?`, im.tenant, c.ID)
+			}
+		}
+	}
+
+	im.applyPool("premium", "prem_emojis", cur.PremEmojis, want.PremEmojis, replace)
+	im.applyPool("non-premium", "nprem_emojis", cur.NpremEmojis, want.NpremEmojis, replace)
+	im.applyPool("premium comment", "comment_prem_emojis", cur.CommentPremEmojis, want.CommentPremEmojis, replace)
+	im.applyPool("non-premium comment", "comment_nprem_emojis", cur.CommentNpremEmojis, want.CommentNpremEmojis, replace)
+
+	roles := make(map[int64]Role, len(cur.Users))
+	for _, u := range cur.Users {
+		roles[u.ID] = u.Role
+	}
+	kept := make(map[int64]bool, len(want.Users))
+	for _, u := range want.Users {
+		kept[u.ID] = true
+		old, ok := roles[u.ID]
+		if ok && old == u.Role {
+			continue
+		}
+		change := fmt.Sprintf("+ user %d as %s", u.ID, u.Role)
+		if ok {
+			change = fmt.Sprintf("~ user %d: %s → %s", u.ID, old, u.Role)
+		}
+		n := im.execN(change, `INSERT INTO users (tenant_id, user_id, role, granted_by, granted_at) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(user_id) DO UPDATE SET role = excluded.role, granted_by = excluded.granted_by, granted_at = excluded.granted_at
+WHERE users.tenant_id = excluded.tenant_id`,
+			im.tenant, u.ID, string(u.Role), u.GrantedBy, time.Now().Unix())
+		if n == 0 && im.err == nil {
+			im.err = fmt.Errorf("user %d: %w", u.ID, ErrOtherTenant)
+		}
+	}
+	if replace {
+		for _, u := range cur.Users {
+			if !kept[u.ID] {
+				im.exec(fmt.Sprintf("- user %d (%s)", u.ID, u.Role), `DELETE FROM users WHERE tenant_id = ? AND user_id = ?`, im.tenant, u.ID)
+			}
+		}
+	}
+}
+
+func (im *importer) applyPool(title, table string, cur, want []string, replace bool) {
+	for _, e := range want {
+		if !slices.Contains(cur, e) {
+			im.exec(fmt.Sprintf("+ %s emoji %s", title, e), `INSERT INTO `+table+` (tenant_id, emoji) VALUES (?, ?) ON CONFLICT DO NOTHING`, im.tenant, e)
+		}
+	}
+	if !replace {
+		return
+	}
+	for _, e := range cur {
+		if !slices.Contains(want, e) {
+			im.exec(fmt.Sprintf("- %s emoji %s", title, e), `DELETE FROM `+table+` WHERE tenant_id = ? AND emoji = ?`, im.tenant, e)
+		}
+	}
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+func reactorsLabel(n int) string {
+	if n == 0 {
+		return "all"
+	}
+	return strconv.Itoa(n)
+}
+
+func chatLabel(enabled bool) string {
+	if enabled {
+		return "active"
+	}
+	return "paused"
+}
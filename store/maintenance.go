@@ -0,0 +1,128 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// backupPrefix names the files written by BackupToDir; pruning only ever
+// touches files with this prefix.
+const backupPrefix = "reactions-"
+
+// Backup writes a consistent snapshot of the live database to path using
+// VACUUM INTO, which is safe while the bot keeps writing.
+func (s *SQLStore) Backup(path string) error {
+	if !s.dialect.supportsFiles {
+		return fmt.Errorf("file backups: %w (use pg_dump)", ErrUnsupported)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup target %s already exists", path)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if _, err := s.db.Exec(`VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("writing backup: %w", err)
+	}
+	return nil
+}
+
+// BackupToDir writes a timestamped backup into dir, creating it if needed,
+// then deletes all but the newest keep backups there. keep <= 0 keeps all.
+func (s *SQLStore) BackupToDir(dir string, keep int) (string, error) {
+	if !s.dialect.supportsFiles {
+		return "", fmt.Errorf("file backups: %w (use pg_dump)", ErrUnsupported)
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, backupPrefix+time.Now().UTC().Format("20060102-150405")+".db")
+	if err := s.Backup(path); err != nil {
+		return "", err
+	}
+	if keep > 0 {
+		if err := pruneBackups(dir, keep); err != nil {
+			return path, fmt.Errorf("pruning old backups: %w", err)
+		}
+	}
+	return path, nil
+}
+
+func pruneBackups(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), backupPrefix) && strings.HasSuffix(e.Name(), ".db") {
+			names = append(names, e.Name())
+		}
+	}
+	// Timestamps in the names sort chronologically.
+	slices.Sort(names)
+	for _, name := range names[:max(0, len(names)-keep)] {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FileSchemaVersion opens the database at path read-only, checks that it is
+// intact and returns its schema version without migrating it.
+func FileSchemaVersion(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	var check string
+	if err := db.QueryRow(`PRAGMA quick_check`).Scan(&check); err != nil {
+		return 0, fmt.Errorf("%s is not a readable SQLite database: %w", path, err)
+	}
+	if check != "ok" {
+		return 0, fmt.Errorf("%s failed the integrity check: %s", path, check)
+	}
+	var version int
+	if err := db.QueryRow(`PRAGMA user_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+	return version, nil
+}
+
+// BackupFile copies the SQLite database at src to dst without migrating it.
+// The WAL is checkpointed into src first and the copy is written with
+// VACUUM INTO, so both hold every committed write and src no longer needs
+// its -wal file.
+func BackupFile(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("backup target %s already exists", dst)
+	}
+	db, err := sql.Open("sqlite", "file:"+src)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err := db.Exec(`PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return fmt.Errorf("checkpointing %s: %w", src, err)
+	}
+	if _, err := db.Exec(`VACUUM INTO ?`, dst); err != nil {
+		return fmt.Errorf("writing backup: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) Vacuum() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`VACUUM`)
+	return err
+}
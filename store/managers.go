@@ -0,0 +1,73 @@
+package store
+
+func (s *SQLStore) AddChatManager(chatID, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`INSERT INTO chat_managers (tenant_id, chat_id, user_id) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`, s.tenant, chatID, userID)
+	return err
+}
+
+func (s *SQLStore) RemoveChatManager(chatID, userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`DELETE FROM chat_managers WHERE tenant_id = ? AND chat_id = ? AND user_id = ?`, s.tenant, chatID, userID)
+	return err
+}
+
+func (s *SQLStore) RemoveManager(userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`DELETE FROM chat_managers WHERE tenant_id = ? AND user_id = ?`, s.tenant, userID)
+	return err
+}
+
+func (s *SQLStore) IsChatManager(chatID, userID int64) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var n int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM chat_managers WHERE tenant_id = ? AND chat_id = ? AND user_id = ?`, s.tenant, chatID, userID).Scan(&n)
+	return n > 0, err
+}
+
+func (s *SQLStore) ManagedChats(userID int64) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.queryIDs(`SELECT chat_id FROM chat_managers WHERE tenant_id = ? AND user_id = ? ORDER BY chat_id`, s.tenant, userID)
+}
+
+// ChatManagers maps every chat that has delegated managers to their user IDs.
+func (s *SQLStore) ChatManagers() (map[int64][]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rows, err := s.db.Query(`SELECT chat_id, user_id FROM chat_managers WHERE tenant_id = ? ORDER BY chat_id, user_id`, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[int64][]int64)
+	for rows.Next() {
+		var chatID, userID int64
+		if err := rows.Scan(&chatID, &userID); err != nil {
+			return nil, err
+		}
+		out[chatID] = append(out[chatID], userID)
+	}
+	return out, rows.Err()
+}
+
+func (s *SQLStore) queryIDs(query string, args ...any) ([]int64, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,280 @@
+package store
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// sqliteMigrations are applied in order and PRAGMA user_version records how
+// many have run, so seed data is only inserted once. Append new steps; never
+// edit or reorder existing ones. postgresMigrations must grow in step, with the
+// same schema at every version.
+var sqliteMigrations = []string{
+	`
+CREATE TABLE IF NOT EXISTS settings (
+key   TEXT PRIMARY KEY,
+value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS chats (
+chat_id INTEGER PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS prem_emojis (
+emoji TEXT PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS nprem_emojis (
+emoji TEXT PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS sessions (
+id         TEXT PRIMARY KEY,
+user_id    INTEGER NOT NULL DEFAULT 0,
+is_premium INTEGER NOT NULL DEFAULT 0,
+retired    INTEGER NOT NULL DEFAULT 0,
+reason     TEXT NOT NULL DEFAULT '',
+updated_at INTEGER NOT NULL DEFAULT 0
+);
+INSERT OR IGNORE INTO settings (key, value) VALUES ('enabled', '1');
+INSERT OR IGNORE INTO prem_emojis (emoji) VALUES ('🐳');
+INSERT OR IGNORE INTO prem_emojis (emoji) VALUES ('❤️');
+INSERT OR IGNORE INTO prem_emojis (emoji) VALUES ('👍');
+INSERT OR IGNORE INTO prem_emojis (emoji) VALUES ('🎉');
+INSERT OR IGNORE INTO prem_emojis (emoji) VALUES ('👌');
+INSERT OR IGNORE INTO nprem_emojis (emoji) VALUES ('👍');
+INSERT OR IGNORE INTO nprem_emojis (emoji) VALUES ('❤️');
+INSERT OR IGNORE INTO nprem_emojis (emoji) VALUES ('🔥');
+`,
+	`ALTER TABLE chats ADD COLUMN enabled INTEGER NOT NULL DEFAULT 1;`,
+	`
+CREATE TABLE roles (
+name TEXT PRIMARY KEY,
+rank INTEGER NOT NULL
+);
+INSERT INTO roles (name, rank) VALUES ('viewer', 1), ('admin', 2), ('owner', 3);
+CREATE TABLE users (
+user_id    INTEGER PRIMARY KEY,
+role       TEXT NOT NULL REFERENCES roles(name),
+granted_by INTEGER NOT NULL DEFAULT 0,
+granted_at INTEGER NOT NULL DEFAULT 0
+);
+`,
+	`
+CREATE TABLE chat_managers (
+chat_id INTEGER NOT NULL,
+user_id INTEGER NOT NULL,
+PRIMARY KEY (chat_id, user_id)
+);
+`,
+	`
+CREATE TABLE audit_log (
+id           INTEGER PRIMARY KEY AUTOINCREMENT,
+at           INTEGER NOT NULL,
+user_id      INTEGER NOT NULL,
+command      TEXT NOT NULL,
+args         TEXT NOT NULL DEFAULT '',
+before_value TEXT NOT NULL DEFAULT '',
+after_value  TEXT NOT NULL DEFAULT ''
+);
+`,
+	`
+CREATE TABLE settings_new (
+tenant_id TEXT NOT NULL DEFAULT 'default',
+key       TEXT NOT NULL,
+value     TEXT NOT NULL,
+PRIMARY KEY (tenant_id, key)
+);
+INSERT INTO settings_new (key, value) SELECT key, value FROM settings;
+DROP TABLE settings;
+ALTER TABLE settings_new RENAME TO settings;
+CREATE TABLE chats_new (
+tenant_id TEXT NOT NULL DEFAULT 'default',
+chat_id   INTEGER NOT NULL,
+enabled   INTEGER NOT NULL DEFAULT 1,
+PRIMARY KEY (tenant_id, chat_id)
+);
+INSERT INTO chats_new (chat_id, enabled) SELECT chat_id, enabled FROM chats;
+DROP TABLE chats;
+ALTER TABLE chats_new RENAME TO chats;
+CREATE TABLE prem_emojis_new (
+tenant_id TEXT NOT NULL DEFAULT 'default',
+emoji     TEXT NOT NULL,
+PRIMARY KEY (tenant_id, emoji)
+);
+INSERT INTO prem_emojis_new (emoji) SELECT emoji FROM prem_emojis;
+DROP TABLE prem_emojis;
+ALTER TABLE prem_emojis_new RENAME TO prem_emojis;
+CREATE TABLE nprem_emojis_new (
+tenant_id TEXT NOT NULL DEFAULT 'default',
+emoji     TEXT NOT NULL,
+PRIMARY KEY (tenant_id, emoji)
+);
+INSERT INTO nprem_emojis_new (emoji) SELECT emoji FROM nprem_emojis;
+DROP TABLE nprem_emojis;
+ALTER TABLE nprem_emojis_new RENAME TO nprem_emojis;
+CREATE TABLE chat_managers_new (
+tenant_id TEXT NOT NULL DEFAULT 'default',
+chat_id   INTEGER NOT NULL,
+user_id   INTEGER NOT NULL,
+PRIMARY KEY (tenant_id, chat_id, user_id)
+);
+INSERT INTO chat_managers_new (chat_id, user_id) SELECT chat_id, user_id FROM chat_managers;
+DROP TABLE chat_managers;
+ALTER TABLE chat_managers_new RENAME TO chat_managers;
+ALTER TABLE users ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE audit_log ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX audit_log_tenant ON audit_log (tenant_id, id);
+`,
+	`
+DELETE FROM chats WHERE chat_id > 0 AND chat_id < 1000000000000 AND EXISTS (
+SELECT 1 FROM chats c WHERE c.tenant_id = chats.tenant_id AND c.chat_id = -1000000000000 - chats.chat_id
+);
+UPDATE chats SET chat_id = -1000000000000 - chat_id WHERE chat_id > 0 AND chat_id < 1000000000000;
+DELETE FROM chat_managers WHERE chat_id > 0 AND chat_id < 1000000000000 AND EXISTS (
+SELECT 1 FROM chat_managers m WHERE m.tenant_id = chat_managers.tenant_id
+AND m.user_id = chat_managers.user_id AND m.chat_id = -1000000000000 - chat_managers.chat_id
+);
+UPDATE chat_managers SET chat_id = -1000000000000 - chat_id WHERE chat_id > 0 AND chat_id < 1000000000000;
+`,
+	`
+CREATE TABLE chat_sessions (
+tenant_id  TEXT NOT NULL DEFAULT 'default',
+chat_id    INTEGER NOT NULL,
+session_id TEXT NOT NULL,
+PRIMARY KEY (tenant_id, chat_id, session_id)
+);
+`,
+	`
+CREATE TABLE session_tags (
+session_id TEXT NOT NULL,
+tag        TEXT NOT NULL,
+PRIMARY KEY (session_id, tag)
+);
+`,
+	`
+ALTER TABLE chats ADD COLUMN reactors INTEGER NOT NULL DEFAULT 0;
+CREATE TABLE session_usage (
+session_id TEXT NOT NULL,
+hour       INTEGER NOT NULL,
+count      INTEGER NOT NULL DEFAULT 0,
+PRIMARY KEY (session_id, hour)
+);
+`,
+	`
+CREATE TABLE pending_reactions (
+tenant_id  TEXT NOT NULL,
+session_id TEXT NOT NULL,
+chat_id    INTEGER NOT NULL,
+msg_id     INTEGER NOT NULL,
+queued_at  INTEGER NOT NULL,
+PRIMARY KEY (tenant_id, session_id, chat_id, msg_id)
+);
+`,
+	`
+ALTER TABLE chats ADD COLUMN comments INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE chats ADD COLUMN discussion_id INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE chats ADD COLUMN post_probability INTEGER NOT NULL DEFAULT 100;
+ALTER TABLE chats ADD COLUMN post_filter TEXT NOT NULL DEFAULT 'all';
+ALTER TABLE chats ADD COLUMN comment_probability INTEGER NOT NULL DEFAULT 100;
+ALTER TABLE chats ADD COLUMN comment_filter TEXT NOT NULL DEFAULT 'all';
+ALTER TABLE pending_reactions ADD COLUMN kind TEXT NOT NULL DEFAULT 'post';
+CREATE TABLE comment_prem_emojis (
+tenant_id TEXT NOT NULL DEFAULT 'default',
+emoji     TEXT NOT NULL,
+PRIMARY KEY (tenant_id, emoji)
+);
+CREATE TABLE comment_nprem_emojis (
+tenant_id TEXT NOT NULL DEFAULT 'default',
+emoji     TEXT NOT NULL,
+PRIMARY KEY (tenant_id, emoji)
+);
+`,
+	`
+ALTER TABLE chats ADD COLUMN albums TEXT NOT NULL DEFAULT 'first';
+`,
+}
+
+func (s *SQLStore) migrate() error {
+	d := s.dialect
+	if d.prepare != "" {
+		if _, err := s.db.Exec(d.prepare); err != nil {
+			return err
+		}
+	}
+	for {
+		done, err := s.migrateStep()
+		if err != nil {
+			return err
+		}
+		if done {
+			s.warnBareChatIDs()
+			return nil
+		}
+	}
+}
+
+// warnBareChatIDs logs the positive chat IDs left over from before IDs were
+// canonical. Migration 7 only converts those that can be bare channel IDs,
+// the same ones LegacyChatID does; anything larger is left for an operator
+// to re-add rather than guessed at.
+func (s *SQLStore) warnBareChatIDs() {
+	rows, err := s.db.Query(`SELECT tenant_id, chat_id, 'chats' FROM chats WHERE chat_id > 0
+UNION ALL SELECT tenant_id, chat_id, 'chat_managers' FROM chat_managers WHERE chat_id > 0`)
+	if err != nil {
+		slog.Warn("Failed to look for unconverted chat IDs", "err", err)
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tenant, table string
+		var id int64
+		if err := rows.Scan(&tenant, &id, &table); err != nil {
+			slog.Warn("Failed to look for unconverted chat IDs", "err", err)
+			return
+		}
+		slog.Warn("Chat ID was not converted to a canonical ID, re-add the chat", "tenant", tenant, "chat_id", id, "table", table)
+	}
+}
+
+// migrateStep applies the next pending migration, re-reading the version
+// under the dialect's lock so replicas starting together don't race.
+func (s *SQLStore) migrateStep() (bool, error) {
+	d := s.dialect
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	if d.lock != "" {
+		if _, err := tx.Exec(d.lock); err != nil {
+			return false, fmt.Errorf("locking schema: %w", err)
+		}
+	}
+	var version int
+	if err := tx.QueryRow(d.readVersion).Scan(&version); err != nil {
+		return false, fmt.Errorf("reading schema version: %w", err)
+	}
+	if version >= len(d.migrations) {
+		return true, tx.Commit()
+	}
+	if _, err := tx.Exec(d.migrations[version]); err != nil {
+		return false, fmt.Errorf("migration %d: %w", version+1, err)
+	}
+	if _, err := tx.Exec(fmt.Sprintf(d.writeVersion, version+1)); err != nil {
+		return false, fmt.Errorf("migration %d: %w", version+1, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("migration %d: %w", version+1, err)
+	}
+	return false, nil
+}
+
+func (s *SQLStore) SchemaVersion() (int, error) {
+	var version int
+	if err := s.db.QueryRow(s.dialect.readVersion).Scan(&version); err != nil {
+		return 0, fmt.Errorf("reading schema version: %w", err)
+	}
+	return version, nil
+}
+
+// LatestSchemaVersion is the schema version this build migrates databases to.
+func LatestSchemaVersion() int {
+	return len(sqliteMigrations)
+}
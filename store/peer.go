@@ -0,0 +1,115 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PeerType is the kind of Telegram peer a chat ID refers to.
+type PeerType string
+
+const (
+	PeerUser    PeerType = "user"
+	PeerChat    PeerType = "chat"    // basic group
+	PeerChannel PeerType = "channel" // supergroup or broadcast channel
+)
+
+// channelOffset is the prefix Bot API style IDs put in front of channel IDs:
+// channel 1234 is -1001234.
+const channelOffset = 1_000_000_000_000
+
+// Peer is a chat as Telegram addresses it: a type and the bare ID within
+// that type. The same bare ID can name both a basic group and a channel, so
+// the type is part of the identity.
+type Peer struct {
+	Type PeerType
+	ID   int64
+}
+
+// ChatID encodes p in the Bot API style used everywhere the store takes a
+// chat ID: users are positive, basic groups negative and channels
+// -100<id>. The encoding is lossless, so it is the canonical form kept in
+// the database and compared on the reaction path.
+func (p Peer) ChatID() int64 {
+	switch p.Type {
+	case PeerChat:
+		return -p.ID
+	case PeerChannel:
+		return -channelOffset - p.ID
+	default:
+		return p.ID
+	}
+}
+
+func (p Peer) String() string {
+	return fmt.Sprintf("%s:%d", p.Type, p.ID)
+}
+
+// PeerOf decodes a canonical chat ID.
+func PeerOf(chatID int64) Peer {
+	switch {
+	case chatID < -channelOffset:
+		return Peer{Type: PeerChannel, ID: -chatID - channelOffset}
+	case chatID < 0:
+		return Peer{Type: PeerChat, ID: -chatID}
+	default:
+		return Peer{Type: PeerUser, ID: chatID}
+	}
+}
+
+var errInvalidChatID = errors.New("not a chat ID: use -100…, -…, a bare channel ID, chat:ID, channel:ID or a t.me/c/… link")
+
+// ParseChatID accepts the ways people usually write a chat ID and returns the
+// canonical one:
+//
+//	-1001234567890          Bot API channel or supergroup
+//	-123456789              Bot API basic group
+//	1234567890              bare ID, as shown by most clients for channels
+//	channel:1234567890      explicit type (also chat:, user:)
+//	https://t.me/c/1234567890/42
+//
+// A bare positive ID is taken as a channel, since users are never
+// monitored and basic groups are always shown negative. One too large to be
+// a channel ID is rejected rather than kept as a user ID.
+func ParseChatID(raw string) (int64, error) {
+	s := strings.TrimSpace(raw)
+	for _, prefix := range []string{"https://", "http://"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	if rest, ok := strings.CutPrefix(s, "t.me/c/"); ok {
+		id, _, _ := strings.Cut(rest, "/")
+		return parsePeer(PeerChannel, id)
+	}
+	if kind, id, ok := strings.Cut(s, ":"); ok {
+		switch t := PeerType(strings.ToLower(kind)); t {
+		case PeerUser, PeerChat, PeerChannel:
+			return parsePeer(t, id)
+		}
+		return 0, errInvalidChatID
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n == 0 || n >= channelOffset {
+		return 0, errInvalidChatID
+	}
+	return LegacyChatID(n), nil
+}
+
+func parsePeer(t PeerType, raw string) (int64, error) {
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id <= 0 || id >= channelOffset {
+		return 0, errInvalidChatID
+	}
+	return Peer{Type: t, ID: id}.ChatID(), nil
+}
+
+// LegacyChatID converts an ID that may be bare, as stored before chat IDs
+// were canonical, into a canonical one. Negative IDs already are; positive
+// ones are taken to be channels.
+func LegacyChatID(id int64) int64 {
+	if id > 0 && id < channelOffset {
+		return Peer{Type: PeerChannel, ID: id}.ChatID()
+	}
+	return id
+}
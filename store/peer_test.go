@@ -0,0 +1,57 @@
+package store
+
+import "testing"
+
+func TestParseChatID(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    int64
+		wantErr bool
+	}{
+		{raw: "-1001234567890", want: -1001234567890},
+		{raw: "-123456789", want: -123456789},
+		{raw: "1234567890", want: -1001234567890},
+		{raw: " 1234567890 ", want: -1001234567890},
+		{raw: "999999999999", want: -1999999999999},
+		{raw: "channel:1234567890", want: -1001234567890},
+		{raw: "CHANNEL:1234567890", want: -1001234567890},
+		{raw: "chat:123456789", want: -123456789},
+		{raw: "user:42", want: 42},
+		{raw: "https://t.me/c/1234567890/42", want: -1001234567890},
+		{raw: "t.me/c/1234567890", want: -1001234567890},
+		{raw: "0", wantErr: true},
+		{raw: "1000000000000", wantErr: true},
+		{raw: "5000000000000", wantErr: true},
+		{raw: "channel:0", wantErr: true},
+		{raw: "channel:1000000000000", wantErr: true},
+		{raw: "chat:-5", wantErr: true},
+		{raw: "group:5", wantErr: true},
+		{raw: "https://t.me/c/abc/42", wantErr: true},
+		{raw: "@channel", wantErr: true},
+		{raw: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseChatID(tt.raw)
+		if tt.wantErr {
+			if err != errInvalidChatID {
+				t.Errorf("ParseChatID(%q) = %d, %v, want errInvalidChatID", tt.raw, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseChatID(%q) = %d, %v, want %d", tt.raw, got, err, tt.want)
+		}
+	}
+}
+
+func TestPeerRoundTrip(t *testing.T) {
+	for _, p := range []Peer{
+		{Type: PeerChannel, ID: 1234567890},
+		{Type: PeerChat, ID: 123456789},
+		{Type: PeerUser, ID: 42},
+	} {
+		if got := PeerOf(p.ChatID()); got != p {
+			t.Errorf("PeerOf(%d) = %v, want %v", p.ChatID(), got, p)
+		}
+	}
+}
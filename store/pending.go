@@ -0,0 +1,68 @@
+package store
+
+import "time"
+
+// PendingReaction is a reaction that was still queued when the process
+// stopped. It names its tenant, so pending reactions are not scoped.
+type PendingReaction struct {
+	Tenant    string
+	SessionID string
+	ChatID    int64
+	MsgID     int32
+	Kind      Kind
+	QueuedAt  time.Time
+}
+
+// SavePendingReactions keeps reactions for TakePendingReactions to hand back
+// on the next start. Saving the same reaction twice keeps one.
+func (s *SQLStore) SavePendingReactions(pending []PendingReaction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, p := range pending {
+		if _, err := tx.Exec(`INSERT INTO pending_reactions (tenant_id, session_id, chat_id, msg_id, kind, queued_at) VALUES (?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+			p.Tenant, p.SessionID, p.ChatID, p.MsgID, string(p.Kind), p.QueuedAt.Unix()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// TakePendingReactions returns the saved reactions, oldest first, and
+// removes them, so each is replayed at most once.
+func (s *SQLStore) TakePendingReactions() ([]PendingReaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.Query(`SELECT tenant_id, session_id, chat_id, msg_id, kind, queued_at FROM pending_reactions ORDER BY queued_at, chat_id, msg_id`)
+	if err != nil {
+		return nil, err
+	}
+	var out []PendingReaction
+	for rows.Next() {
+		var p PendingReaction
+		var at int64
+		if err := rows.Scan(&p.Tenant, &p.SessionID, &p.ChatID, &p.MsgID, &p.Kind, &at); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		p.QueuedAt = time.Unix(at, 0)
+		out = append(out, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`DELETE FROM pending_reactions`); err != nil {
+		return nil, err
+	}
+	return out, tx.Commit()
+}
@@ -0,0 +1,152 @@
+package store
+
+// postgresMigrations mirror sqliteMigrations step for step. IDs and Unix
+// timestamps are BIGINT because Postgres INTEGER is 32-bit; booleans stay
+// 0/1 integers so both backends share every query.
+var postgresMigrations = []string{
+	`
+CREATE TABLE settings (
+key   TEXT PRIMARY KEY,
+value TEXT NOT NULL
+);
+CREATE TABLE chats (
+chat_id BIGINT PRIMARY KEY
+);
+CREATE TABLE prem_emojis (
+emoji TEXT PRIMARY KEY
+);
+CREATE TABLE nprem_emojis (
+emoji TEXT PRIMARY KEY
+);
+CREATE TABLE sessions (
+id         TEXT PRIMARY KEY,
+user_id    BIGINT NOT NULL DEFAULT 0,
+is_premium INTEGER NOT NULL DEFAULT 0,
+retired    INTEGER NOT NULL DEFAULT 0,
+reason     TEXT NOT NULL DEFAULT '',
+updated_at BIGINT NOT NULL DEFAULT 0
+);
+INSERT INTO settings (key, value) VALUES ('enabled', '1') ON CONFLICT DO NOTHING;
+INSERT INTO prem_emojis (emoji) VALUES ('🐳'), ('❤️'), ('👍'), ('🎉'), ('👌') ON CONFLICT DO NOTHING;
+INSERT INTO nprem_emojis (emoji) VALUES ('👍'), ('❤️'), ('🔥') ON CONFLICT DO NOTHING;
+`,
+	`ALTER TABLE chats ADD COLUMN enabled INTEGER NOT NULL DEFAULT 1;`,
+	`
+CREATE TABLE roles (
+name TEXT PRIMARY KEY,
+rank INTEGER NOT NULL
+);
+INSERT INTO roles (name, rank) VALUES ('viewer', 1), ('admin', 2), ('owner', 3);
+CREATE TABLE users (
+user_id    BIGINT PRIMARY KEY,
+role       TEXT NOT NULL REFERENCES roles(name),
+granted_by BIGINT NOT NULL DEFAULT 0,
+granted_at BIGINT NOT NULL DEFAULT 0
+);
+`,
+	`
+CREATE TABLE chat_managers (
+chat_id BIGINT NOT NULL,
+user_id BIGINT NOT NULL,
+PRIMARY KEY (chat_id, user_id)
+);
+`,
+	`
+CREATE TABLE audit_log (
+id           BIGSERIAL PRIMARY KEY,
+at           BIGINT NOT NULL,
+user_id      BIGINT NOT NULL,
+command      TEXT NOT NULL,
+args         TEXT NOT NULL DEFAULT '',
+before_value TEXT NOT NULL DEFAULT '',
+after_value  TEXT NOT NULL DEFAULT ''
+);
+`,
+	`
+ALTER TABLE settings ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE settings DROP CONSTRAINT settings_pkey;
+ALTER TABLE settings ADD PRIMARY KEY (tenant_id, key);
+ALTER TABLE chats ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE chats DROP CONSTRAINT chats_pkey;
+ALTER TABLE chats ADD PRIMARY KEY (tenant_id, chat_id);
+ALTER TABLE prem_emojis ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE prem_emojis DROP CONSTRAINT prem_emojis_pkey;
+ALTER TABLE prem_emojis ADD PRIMARY KEY (tenant_id, emoji);
+ALTER TABLE nprem_emojis ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE nprem_emojis DROP CONSTRAINT nprem_emojis_pkey;
+ALTER TABLE nprem_emojis ADD PRIMARY KEY (tenant_id, emoji);
+ALTER TABLE chat_managers ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE chat_managers DROP CONSTRAINT chat_managers_pkey;
+ALTER TABLE chat_managers ADD PRIMARY KEY (tenant_id, chat_id, user_id);
+ALTER TABLE users ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE audit_log ADD COLUMN tenant_id TEXT NOT NULL DEFAULT 'default';
+CREATE INDEX audit_log_tenant ON audit_log (tenant_id, id);
+`,
+	`
+DELETE FROM chats WHERE chat_id > 0 AND chat_id < 1000000000000 AND EXISTS (
+SELECT 1 FROM chats c WHERE c.tenant_id = chats.tenant_id AND c.chat_id = -1000000000000 - chats.chat_id
+);
+UPDATE chats SET chat_id = -1000000000000 - chat_id WHERE chat_id > 0 AND chat_id < 1000000000000;
+DELETE FROM chat_managers WHERE chat_id > 0 AND chat_id < 1000000000000 AND EXISTS (
+SELECT 1 FROM chat_managers m WHERE m.tenant_id = chat_managers.tenant_id
+AND m.user_id = chat_managers.user_id AND m.chat_id = -1000000000000 - chat_managers.chat_id
+);
+UPDATE chat_managers SET chat_id = -1000000000000 - chat_id WHERE chat_id > 0 AND chat_id < 1000000000000;
+`,
+	`
+CREATE TABLE chat_sessions (
+tenant_id  TEXT NOT NULL DEFAULT 'default',
+chat_id    BIGINT NOT NULL,
+session_id TEXT NOT NULL,
+PRIMARY KEY (tenant_id, chat_id, session_id)
+);
+`,
+	`
+CREATE TABLE session_tags (
+session_id TEXT NOT NULL,
+tag        TEXT NOT NULL,
+PRIMARY KEY (session_id, tag)
+);
+`,
+	`
+ALTER TABLE chats ADD COLUMN reactors INTEGER NOT NULL DEFAULT 0;
+CREATE TABLE session_usage (
+session_id TEXT NOT NULL,
+hour       BIGINT NOT NULL,
+count      INTEGER NOT NULL DEFAULT 0,
+PRIMARY KEY (session_id, hour)
+);
+`,
+	`
+CREATE TABLE pending_reactions (
+tenant_id  TEXT NOT NULL,
+session_id TEXT NOT NULL,
+chat_id    BIGINT NOT NULL,
+msg_id     INTEGER NOT NULL,
+queued_at  BIGINT NOT NULL,
+PRIMARY KEY (tenant_id, session_id, chat_id, msg_id)
+);
+`,
+	`
+ALTER TABLE chats ADD COLUMN comments INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE chats ADD COLUMN discussion_id BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE chats ADD COLUMN post_probability INTEGER NOT NULL DEFAULT 100;
+ALTER TABLE chats ADD COLUMN post_filter TEXT NOT NULL DEFAULT 'all';
+ALTER TABLE chats ADD COLUMN comment_probability INTEGER NOT NULL DEFAULT 100;
+ALTER TABLE chats ADD COLUMN comment_filter TEXT NOT NULL DEFAULT 'all';
+ALTER TABLE pending_reactions ADD COLUMN kind TEXT NOT NULL DEFAULT 'post';
+CREATE TABLE comment_prem_emojis (
+tenant_id TEXT NOT NULL DEFAULT 'default',
+emoji     TEXT NOT NULL,
+PRIMARY KEY (tenant_id, emoji)
+);
+CREATE TABLE comment_nprem_emojis (
+tenant_id TEXT NOT NULL DEFAULT 'default',
+emoji     TEXT NOT NULL,
+PRIMARY KEY (tenant_id, emoji)
+);
+`,
+	`
+ALTER TABLE chats ADD COLUMN albums TEXT NOT NULL DEFAULT 'first';
+`,
+}
@@ -0,0 +1,150 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type SessionRecord struct {
+	ID        string
+	UserID    int64
+	IsPremium bool
+	Retired   bool
+	Reason    string
+	UpdatedAt time.Time
+}
+
+func (s *SQLStore) RecordSession(id string, userID int64, isPremium bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`INSERT INTO sessions (id, user_id, is_premium, updated_at) VALUES (?, ?, ?, ?)
+ON CONFLICT(id) DO UPDATE SET user_id = excluded.user_id, is_premium = excluded.is_premium, updated_at = excluded.updated_at`,
+		id, userID, boolToInt(isPremium), time.Now().Unix())
+	return err
+}
+
+func (s *SQLStore) RetireSession(id, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`INSERT INTO sessions (id, retired, reason, updated_at) VALUES (?, 1, ?, ?)
+ON CONFLICT(id) DO UPDATE SET retired = 1, reason = excluded.reason, updated_at = excluded.updated_at`,
+		id, reason, time.Now().Unix())
+	return err
+}
+
+func (s *SQLStore) GetSession(id string) (SessionRecord, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, err := scanSession(s.db.QueryRow(`SELECT id, user_id, is_premium, retired, reason, updated_at FROM sessions WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return SessionRecord{}, false, nil
+	}
+	if err != nil {
+		return SessionRecord{}, false, err
+	}
+	return rec, true, nil
+}
+
+func (s *SQLStore) GetSessions() ([]SessionRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rows, err := s.db.Query(`SELECT id, user_id, is_premium, retired, reason, updated_at FROM sessions ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []SessionRecord
+	for rows.Next() {
+		rec, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rec)
+	}
+	return out, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSession(row rowScanner) (SessionRecord, error) {
+	var rec SessionRecord
+	var premium, retired int
+	var updated int64
+	if err := row.Scan(&rec.ID, &rec.UserID, &premium, &retired, &rec.Reason, &updated); err != nil {
+		return SessionRecord{}, err
+	}
+	rec.IsPremium = premium == 1
+	rec.Retired = retired == 1
+	rec.UpdatedAt = time.Unix(updated, 0)
+	return rec, nil
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// TagSession adds tags to a session. Tags are shared by all tenants, like the
+// sessions themselves.
+func (s *SQLStore) TagSession(id string, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, tag := range tags {
+		if _, err := tx.Exec(`INSERT INTO session_tags (session_id, tag) VALUES (?, ?) ON CONFLICT DO NOTHING`, id, tag); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// UntagSession removes tags from a session, or all of them when tags is
+// empty.
+func (s *SQLStore) UntagSession(id string, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(tags) == 0 {
+		_, err := s.db.Exec(`DELETE FROM session_tags WHERE session_id = ?`, id)
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, tag := range tags {
+		if _, err := tx.Exec(`DELETE FROM session_tags WHERE session_id = ? AND tag = ?`, id, tag); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SessionTags maps every tagged session to its tags, sorted.
+func (s *SQLStore) SessionTags() (map[string][]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rows, err := s.db.Query(`SELECT session_id, tag FROM session_tags ORDER BY session_id, tag`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	out := make(map[string][]string)
+	for rows.Next() {
+		var id, tag string
+		if err := rows.Scan(&id, &tag); err != nil {
+			return nil, err
+		}
+		out[id] = append(out[id], tag)
+	}
+	return out, rows.Err()
+}
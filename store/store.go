@@ -2,131 +2,432 @@ package store
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
+)
 
-	_ "modernc.org/sqlite"
+var (
+	ErrChatNotFound = errors.New("chat is not in the auto-react list")
+	// ErrOtherTenant is returned when a user already belongs to a different
+	// tenant; a user ID can only hold a role in one.
+	ErrOtherTenant = errors.New("user belongs to another tenant")
+	// ErrUnsupported is returned by operations the storage backend cannot do,
+	// such as file backups on Postgres.
+	ErrUnsupported = errors.New("not supported by this storage backend")
 )
 
-type Store struct {
-	mu sync.RWMutex
-	db *sql.DB
+// DefaultTenant owns all data created before tenants existed, and every
+// session, owner and CLI command that doesn't name a tenant.
+const DefaultTenant = "default"
+
+// Store is everything the bot and the CLI need from persistent storage.
+// SQLStore implements it for SQLite and Postgres.
+//
+// A Store is scoped to one tenant: settings, chats, pools, users, managers
+// and the audit log of other tenants are invisible through it. ForTenant
+// returns a view of the same database scoped to another tenant. Sessions,
+// roles and file-level maintenance are shared by all tenants.
+type Store interface {
+	Tenant() string
+	ForTenant(tenant string) Store
+	// InitTenant seeds the default settings and emoji pools the first time a
+	// tenant is used.
+	InitTenant() error
+
+	IsEnabled() (bool, error)
+	SetEnabled(enabled bool) error
+
+	HasChat(chatID int64) (bool, error)
+	IsChatActive(chatID int64) (bool, error)
+	SetChatEnabled(chatID int64, enabled bool) error
+	// AddChat starts monitoring a chat with the settings in d. A chat that
+	// is already monitored keeps its own.
+	AddChat(chatID int64, d ChatDefaults) error
+	RemoveChat(chatID int64) error
+	GetChats() ([]int64, error)
+	ListChats() ([]Chat, error)
+	// SetChatReactors makes n sessions react to each message in a chat; 0
+	// lets every eligible session react.
+	SetChatReactors(chatID int64, n int) error
+	ChatReactors(chatID int64) (int, error)
+	// MigrateChat is not scoped: it moves the chat in every tenant.
+	MigrateChat(from, to int64) ([]string, error)
+	GetChat(chatID int64) (Chat, bool, error)
+	SetChatComments(chatID int64, on bool) error
+	SetChatDiscussion(chatID, discussionID int64) error
+	SetChatRule(chatID int64, kind Kind, r Rule) error
+	SetChatAlbums(chatID int64, mode AlbumMode) error
+	DiscussionParent(discussionID int64) (int64, bool, error)
+
+	AddPremEmoji(emoji string) error
+	AddNpremEmoji(emoji string) error
+	RemovePremEmoji(emoji string) error
+	RemoveNpremEmoji(emoji string) error
+	GetPremEmojis() ([]string, error)
+	GetNpremEmojis() ([]string, error)
+	AddCommentEmoji(premium bool, emoji string) error
+	RemoveCommentEmoji(premium bool, emoji string) error
+	GetCommentEmojis(premium bool) ([]string, error)
+
+	RecordSession(id string, userID int64, isPremium bool) error
+	RetireSession(id, reason string) error
+	GetSession(id string) (SessionRecord, bool, error)
+	GetSessions() ([]SessionRecord, error)
+	TagSession(id string, tags []string) error
+	UntagSession(id string, tags []string) error
+	SessionTags() (map[string][]string, error)
+	AddSessionUsage(id string, at time.Time, n int) error
+	SessionUsage(since time.Time) ([]UsageBucket, error)
+	SavePendingReactions(pending []PendingReaction) error
+	TakePendingReactions() ([]PendingReaction, error)
+
+	FindUser(userID int64) (User, bool, error)
+	GetUserRole(userID int64) (Role, bool, error)
+	SetUserRole(userID int64, role Role, grantedBy int64) error
+	RemoveUser(userID int64) (bool, error)
+	ListUsers() ([]User, error)
+
+	AddChatManager(chatID, userID int64) error
+	RemoveChatManager(chatID, userID int64) error
+	RemoveManager(userID int64) error
+	IsChatManager(chatID, userID int64) (bool, error)
+	ManagedChats(userID int64) ([]int64, error)
+	ChatManagers() (map[int64][]int64, error)
+
+	// Assignments hold session selectors: a session ID, or #tag for every
+	// session carrying that tag.
+	AssignSessions(chatID int64, sessionIDs []string) error
+	UnassignSessions(chatID int64, sessionIDs []string) error
+	ChatSessions(chatID int64) ([]string, error)
+	AllChatSessions() (map[int64][]string, error)
+
+	AddAudit(e AuditEntry) error
+	ListAudit(limit, offset int) ([]AuditEntry, error)
+	CountAudit() (int, error)
+
+	Export() (*Snapshot, error)
+	Import(snap *Snapshot, mode ImportMode, dryRun bool) ([]string, error)
+
+	Backup(path string) error
+	BackupToDir(dir string, keep int) (string, error)
+	Vacuum() error
+	SchemaVersion() (int, error)
+	Close() error
 }
 
-func New(path string) (*Store, error) {
-	db, err := sql.Open("sqlite", path)
+// SQLStore is the database/sql implementation of Store. Queries are written
+// once with ? placeholders; the dialect rewrites them for the driver.
+type SQLStore struct {
+	mu      *sync.RWMutex
+	db      *db
+	dialect *dialect
+	tenant  string
+}
+
+var _ Store = (*SQLStore)(nil)
+
+// New opens the store described by dsn: a postgres:// or postgresql:// URL
+// selects Postgres, anything else is taken as a SQLite file path. The store
+// is scoped to DefaultTenant.
+func New(dsn string) (Store, error) {
+	d := sqlite
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		d = postgres
+	}
+	conn, err := sql.Open(d.driver, d.dsn(dsn))
 	if err != nil {
-		return nil, fmt.Errorf("opening sqlite db: %w", err)
+		return nil, fmt.Errorf("opening %s db: %w", d.name, err)
 	}
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("connecting to sqlite db: %w", err)
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("connecting to %s db: %w", d.name, err)
 	}
-	s := &Store{db: db}
+	s := &SQLStore{mu: &sync.RWMutex{}, db: &db{DB: conn, dialect: d}, dialect: d, tenant: DefaultTenant}
 	if err := s.migrate(); err != nil {
+		conn.Close()
 		return nil, fmt.Errorf("running migrations: %w", err)
 	}
 	return s, nil
 }
 
-func (s *Store) migrate() error {
-	_, err := s.db.Exec(`
-CREATE TABLE IF NOT EXISTS settings (
-key   TEXT PRIMARY KEY,
-value TEXT NOT NULL
-);
-CREATE TABLE IF NOT EXISTS chats (
-chat_id INTEGER PRIMARY KEY
-);
-CREATE TABLE IF NOT EXISTS prem_emojis (
-emoji TEXT PRIMARY KEY
-);
-CREATE TABLE IF NOT EXISTS nprem_emojis (
-emoji TEXT PRIMARY KEY
-);
-INSERT OR IGNORE INTO settings (key, value) VALUES ('enabled', '1');
-INSERT OR IGNORE INTO prem_emojis (emoji) VALUES ('🐳');
-INSERT OR IGNORE INTO prem_emojis (emoji) VALUES ('❤️');
-INSERT OR IGNORE INTO prem_emojis (emoji) VALUES ('👍');
-INSERT OR IGNORE INTO prem_emojis (emoji) VALUES ('🎉');
-INSERT OR IGNORE INTO prem_emojis (emoji) VALUES ('👌');
-INSERT OR IGNORE INTO nprem_emojis (emoji) VALUES ('👍');
-INSERT OR IGNORE INTO nprem_emojis (emoji) VALUES ('❤️');
-INSERT OR IGNORE INTO nprem_emojis (emoji) VALUES ('🔥');
-`)
-	return err
+func (s *SQLStore) Tenant() string {
+	return s.tenant
+}
+
+// ForTenant returns a view sharing s's connection and lock. Closing any view
+// closes the database for all of them.
+func (s *SQLStore) ForTenant(tenant string) Store {
+	return &SQLStore{mu: s.mu, db: s.db, dialect: s.dialect, tenant: tenant}
+}
+
+// defaultEmojis seed new tenants, matching the pools of the first migration.
+var defaultEmojis = map[string][]string{
+	"prem_emojis":  {"🐳", "❤️", "👍", "🎉", "👌"},
+	"nprem_emojis": {"👍", "❤️", "🔥"},
+}
+
+func (s *SQLStore) InitTenant() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	res, err := tx.Exec(`INSERT INTO settings (tenant_id, key, value) VALUES (?, 'enabled', '1') ON CONFLICT DO NOTHING`, s.tenant)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	for table, emojis := range defaultEmojis {
+		for _, e := range emojis {
+			if _, err := tx.Exec(`INSERT INTO `+table+` (tenant_id, emoji) VALUES (?, ?) ON CONFLICT DO NOTHING`, s.tenant, e); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
 }
 
-func (s *Store) IsEnabled() bool {
+// IsEnabled reports the tenant's switch. A missing setting reads as disabled;
+// any other failure is returned rather than guessed at.
+func (s *SQLStore) IsEnabled() (bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	var v string
-	_ = s.db.QueryRow(`SELECT value FROM settings WHERE key = 'enabled'`).Scan(&v)
-	return v == "1"
+	err := s.db.QueryRow(`SELECT value FROM settings WHERE tenant_id = ? AND key = 'enabled'`, s.tenant).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("reading enabled setting: %w", err)
+	}
+	return v == "1", nil
 }
 
-func (s *Store) SetEnabled(enabled bool) error {
+func (s *SQLStore) SetEnabled(enabled bool) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	v := "0"
 	if enabled {
 		v = "1"
 	}
-	_, err := s.db.Exec(`INSERT INTO settings (key, value) VALUES ('enabled', ?) ON CONFLICT(key) DO UPDATE SET value = excluded.value`, v)
+	_, err := s.db.Exec(`INSERT INTO settings (tenant_id, key, value) VALUES (?, 'enabled', ?) ON CONFLICT(tenant_id, key) DO UPDATE SET value = excluded.value`, s.tenant, v)
 	return err
 }
 
-func (s *Store) HasChat(chatID int64) bool {
+func (s *SQLStore) HasChat(chatID int64) (bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	var id int64
-	err := s.db.QueryRow(`SELECT chat_id FROM chats WHERE chat_id = ?`, chatID).Scan(&id)
-	return err == nil
+	err := s.db.QueryRow(`SELECT chat_id FROM chats WHERE tenant_id = ? AND chat_id = ?`, s.tenant, chatID).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("looking up chat %d: %w", chatID, err)
+	}
+	return true, nil
+}
+
+func (s *SQLStore) IsChatActive(chatID int64) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var enabled int
+	err := s.db.QueryRow(`SELECT enabled FROM chats WHERE tenant_id = ? AND chat_id = ?`, s.tenant, chatID).Scan(&enabled)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("looking up chat %d: %w", chatID, err)
+	}
+	return enabled == 1, nil
+}
+
+func (s *SQLStore) SetChatEnabled(chatID int64, enabled bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, err := s.db.Exec(`UPDATE chats SET enabled = ? WHERE tenant_id = ? AND chat_id = ?`, boolToInt(enabled), s.tenant, chatID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrChatNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) SetChatReactors(chatID int64, n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, err := s.db.Exec(`UPDATE chats SET reactors = ? WHERE tenant_id = ? AND chat_id = ?`, n, s.tenant, chatID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrChatNotFound
+	}
+	return nil
+}
+
+func (s *SQLStore) ChatReactors(chatID int64) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var n int
+	err := s.db.QueryRow(`SELECT reactors FROM chats WHERE tenant_id = ? AND chat_id = ?`, s.tenant, chatID).Scan(&n)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	return n, err
+}
+
+// ChatDefaults are the settings a chat starts with when it is added.
+type ChatDefaults struct {
+	Paused   bool
+	Reactors int
+	Comments bool
+	Post     Rule
+	Comment  Rule
+	Albums   AlbumMode
+}
+
+// DefaultChatDefaults start a chat active, with every session reacting to
+// every post and once to each album, and comments off.
+var DefaultChatDefaults = ChatDefaults{Post: DefaultRule, Comment: DefaultRule, Albums: DefaultAlbumMode}
+
+// Validate checks that d can be stored.
+func (d ChatDefaults) Validate() error {
+	if d.Reactors < 0 {
+		return fmt.Errorf("reactors %d is negative", d.Reactors)
+	}
+	if err := d.Post.Validate(); err != nil {
+		return fmt.Errorf("post rule: %w", err)
+	}
+	if err := d.Comment.Validate(); err != nil {
+		return fmt.Errorf("comment rule: %w", err)
+	}
+	_, err := ParseAlbumMode(string(d.Albums))
+	return err
+}
+
+func (s *SQLStore) AddChat(chatID int64, d ChatDefaults) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`INSERT INTO chats (tenant_id, chat_id, enabled, reactors, comments, post_probability, post_filter, comment_probability, comment_filter, albums)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		s.tenant, chatID, boolToInt(!d.Paused), d.Reactors, boolToInt(d.Comments),
+		d.Post.Probability, string(d.Post.Filter), d.Comment.Probability, string(d.Comment.Filter), string(d.Albums))
+	return err
+}
+
+func (s *SQLStore) RemoveChat(chatID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM chats WHERE tenant_id = ? AND chat_id = ?`, s.tenant, chatID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM chat_managers WHERE tenant_id = ? AND chat_id = ?`, s.tenant, chatID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM chat_sessions WHERE tenant_id = ? AND chat_id = ?`, s.tenant, chatID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// perChatTables lists every table keyed by chat, with the columns to carry
+// over when a chat changes ID. Tables added later must be listed here.
+var perChatTables = []struct{ table, columns string }{
+	{"chats", "enabled, reactors, comments, discussion_id, post_probability, post_filter, comment_probability, comment_filter, albums"},
+	{"chat_managers", "user_id"},
+	{"chat_sessions", "session_id"},
+}
+
+// MigrateChat moves a chat and everything keyed by it from one ID to
+// another, in every tenant that monitors it, as when Telegram upgrades a
+// basic group to a supergroup. Settings already stored under the new ID win.
+// It returns the tenants whose data moved, so running it twice is harmless.
+func (s *SQLStore) MigrateChat(from, to int64) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	tenants, err := queryStrings(tx, `SELECT tenant_id FROM chats WHERE chat_id = ? ORDER BY tenant_id`, from)
+	if err != nil || len(tenants) == 0 {
+		return nil, err
+	}
+	for _, t := range perChatTables {
+		insert := fmt.Sprintf(`INSERT INTO %[1]s (tenant_id, chat_id, %[2]s) SELECT tenant_id, ?, %[2]s FROM %[1]s WHERE chat_id = ? ON CONFLICT DO NOTHING`, t.table, t.columns)
+		if _, err := tx.Exec(insert, to, from); err != nil {
+			return nil, fmt.Errorf("migrating %s: %w", t.table, err)
+		}
+		if _, err := tx.Exec(`DELETE FROM `+t.table+` WHERE chat_id = ?`, from); err != nil {
+			return nil, fmt.Errorf("migrating %s: %w", t.table, err)
+		}
+	}
+	return tenants, tx.Commit()
 }
 
-func (s *Store) AddChat(chatID int64) error {
+func (s *SQLStore) AddPremEmoji(emoji string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	_, err := s.db.Exec(`INSERT OR IGNORE INTO chats (chat_id) VALUES (?)`, chatID)
+	_, err := s.db.Exec(`INSERT INTO prem_emojis (tenant_id, emoji) VALUES (?, ?) ON CONFLICT DO NOTHING`, s.tenant, emoji)
 	return err
 }
 
-func (s *Store) RemoveChat(chatID int64) error {
+func (s *SQLStore) AddNpremEmoji(emoji string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	_, err := s.db.Exec(`DELETE FROM chats WHERE chat_id = ?`, chatID)
+	_, err := s.db.Exec(`INSERT INTO nprem_emojis (tenant_id, emoji) VALUES (?, ?) ON CONFLICT DO NOTHING`, s.tenant, emoji)
 	return err
 }
 
-func (s *Store) AddPremEmoji(emoji string) error {
+func (s *SQLStore) RemovePremEmoji(emoji string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	_, err := s.db.Exec(`INSERT OR IGNORE INTO prem_emojis (emoji) VALUES (?)`, emoji)
+	_, err := s.db.Exec(`DELETE FROM prem_emojis WHERE tenant_id = ? AND emoji = ?`, s.tenant, emoji)
 	return err
 }
 
-func (s *Store) AddNpremEmoji(emoji string) error {
+func (s *SQLStore) RemoveNpremEmoji(emoji string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	_, err := s.db.Exec(`INSERT OR IGNORE INTO nprem_emojis (emoji) VALUES (?)`, emoji)
+	_, err := s.db.Exec(`DELETE FROM nprem_emojis WHERE tenant_id = ? AND emoji = ?`, s.tenant, emoji)
 	return err
 }
 
-func (s *Store) GetPremEmojis() ([]string, error) {
+func (s *SQLStore) GetPremEmojis() ([]string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.queryEmojis(`SELECT emoji FROM prem_emojis`)
+	return s.queryEmojis(`SELECT emoji FROM prem_emojis WHERE tenant_id = ?`)
 }
 
-func (s *Store) GetNpremEmojis() ([]string, error) {
+func (s *SQLStore) GetNpremEmojis() ([]string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.queryEmojis(`SELECT emoji FROM nprem_emojis`)
+	return s.queryEmojis(`SELECT emoji FROM nprem_emojis WHERE tenant_id = ?`)
 }
 
-func (s *Store) GetChats() ([]int64, error) {
+func (s *SQLStore) GetChats() ([]int64, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	rows, err := s.db.Query(`SELECT chat_id FROM chats`)
+	rows, err := s.db.Query(`SELECT chat_id FROM chats WHERE tenant_id = ?`, s.tenant)
 	if err != nil {
 		return nil, err
 	}
@@ -142,12 +443,45 @@ func (s *Store) GetChats() ([]int64, error) {
 	return ids, rows.Err()
 }
 
-func (s *Store) Close() error {
+type Chat struct {
+	ID      int64
+	Enabled bool
+	// Reactors is how many sessions react to each message; 0 means all.
+	Reactors int
+	// Comments also reacts to comments in the linked discussion group,
+	// DiscussionID once it is known.
+	Comments     bool
+	DiscussionID int64
+	Post         Rule
+	Comment      Rule
+	Albums       AlbumMode
+}
+
+func (s *SQLStore) ListChats() ([]Chat, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rows, err := s.db.Query(`SELECT `+chatColumns+` FROM chats WHERE tenant_id = ? ORDER BY chat_id`, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var chats []Chat
+	for rows.Next() {
+		c, err := scanChat(rows)
+		if err != nil {
+			return nil, err
+		}
+		chats = append(chats, c)
+	}
+	return chats, rows.Err()
+}
+
+func (s *SQLStore) Close() error {
 	return s.db.Close()
 }
 
-func (s *Store) queryEmojis(query string) ([]string, error) {
-	rows, err := s.db.Query(query)
+func (s *SQLStore) queryEmojis(query string) ([]string, error) {
+	rows, err := s.db.Query(query, s.tenant)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,656 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// postgresTestEnv names a Postgres URL to run the conformance suite against.
+// Each test gets its own schema, dropped when it ends.
+const postgresTestEnv = "TEST_DATABASE_URL"
+
+// backends opens an empty store for a test; every backend must pass the
+// same suite.
+var backends = []struct {
+	name string
+	open func(t *testing.T) Store
+}{
+	{"sqlite", openSQLite},
+	{"postgres", openPostgres},
+}
+
+func openSQLite(t *testing.T) Store {
+	st, err := New(sqliteDSN(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func openPostgres(t *testing.T) Store {
+	st, err := New(postgresDSN(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+	return st
+}
+
+func sqliteDSN(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "reactions.db")
+}
+
+// postgresDSN creates a schema for the test and returns a DSN that uses it.
+func postgresDSN(t *testing.T) string {
+	dsn := os.Getenv(postgresTestEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", postgresTestEnv)
+	}
+	admin, err := sql.Open(postgres.driver, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+	schema := fmt.Sprintf("reactionbot_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec(`CREATE SCHEMA ` + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Exec(`DROP SCHEMA ` + schema + ` CASCADE`) })
+	sep := "?"
+	if strings.Contains(dsn, "?") {
+		sep = "&"
+	}
+	return dsn + sep + "search_path=" + schema
+}
+
+// openAtVersion opens the database at dsn and migrates it only as far as
+// version, as an older build would have left it.
+func openAtVersion(t *testing.T, d *dialect, dsn string, version int) *SQLStore {
+	conn, err := sql.Open(d.driver, d.dsn(dsn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	s := &SQLStore{mu: &sync.RWMutex{}, db: &db{DB: conn, dialect: d}, dialect: d, tenant: DefaultTenant}
+	if d.prepare != "" {
+		if _, err := conn.Exec(d.prepare); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range version {
+		if _, err := s.migrateStep(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+// TestStore runs every conformance test on every backend.
+func TestStore(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, st Store)
+	}{
+		{"Schema", testSchema},
+		{"Enabled", testEnabled},
+		{"Chats", testChats},
+		{"ChatSettings", testChatSettings},
+		{"Emojis", testEmojis},
+		{"Assignments", testAssignments},
+		{"Tenants", testTenants},
+		{"MigrateChat", testMigrateChat},
+		{"Users", testUsers},
+		{"Managers", testManagers},
+		{"Sessions", testSessions},
+		{"Usage", testUsage},
+		{"PendingReactions", testPendingReactions},
+		{"Audit", testAudit},
+		{"ExportImport", testExportImport},
+	}
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					tt.run(t, b.open(t))
+				})
+			}
+		})
+	}
+}
+
+// TestMigrateBareChatIDs runs migration 7 over chats stored before IDs were
+// canonical.
+func TestMigrateBareChatIDs(t *testing.T) {
+	for _, b := range []struct {
+		name string
+		d    *dialect
+		dsn  func(t *testing.T) string
+	}{
+		{"sqlite", sqlite, sqliteDSN},
+		{"postgres", postgres, postgresDSN},
+	} {
+		t.Run(b.name, func(t *testing.T) {
+			s := openAtVersion(t, b.d, b.dsn(t), 6)
+			// 1002 was added again later as -1001000001002, paused; the
+			// canonical row wins. -1001 is a basic group and 5000000000000
+			// is too large to be a bare channel ID.
+			_, err := s.db.Exec(`INSERT INTO chats (chat_id, enabled) VALUES
+(1001, 1), (1002, 1), (-1000000001002, 0), (-1001, 1), (5000000000000, 1)`)
+			check(t, err)
+			_, err = s.db.Exec(`INSERT INTO chat_managers (chat_id, user_id) VALUES
+(1002, 7), (-1000000001002, 7), (1001, 8), (5000000000000, 8)`)
+			check(t, err)
+			check(t, s.migrate())
+
+			ids, err := s.GetChats()
+			check(t, err)
+			if want := []int64{-1000000001002, -1000000001001, -1001, 5000000000000}; !reflect.DeepEqual(sorted(ids), want) {
+				t.Errorf("GetChats() = %v, want %v", sorted(ids), want)
+			}
+			if active, _ := s.IsChatActive(-1000000001002); active {
+				t.Error("the bare duplicate replaced the canonical chat")
+			}
+			if active, _ := s.IsChatActive(-1000000001001); !active {
+				t.Error("a converted chat lost its settings")
+			}
+			if chats, _ := s.ManagedChats(7); !reflect.DeepEqual(chats, []int64{-1000000001002}) {
+				t.Errorf("ManagedChats(7) = %v", chats)
+			}
+			if chats, _ := s.ManagedChats(8); !reflect.DeepEqual(sorted(chats), []int64{-1000000001001, 5000000000000}) {
+				t.Errorf("ManagedChats(8) = %v", chats)
+			}
+		})
+	}
+}
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sorted[S ~[]E, E string | int64](s S) S {
+	s = slices.Clone(s)
+	slices.Sort(s)
+	return s
+}
+
+func testSchema(t *testing.T, st Store) {
+	v, err := st.SchemaVersion()
+	check(t, err)
+	if v != LatestSchemaVersion() {
+		t.Errorf("schema version = %d, want %d", v, LatestSchemaVersion())
+	}
+}
+
+func testEnabled(t *testing.T, st Store) {
+	on, err := st.IsEnabled()
+	check(t, err)
+	if !on {
+		t.Error("a new store is disabled")
+	}
+	check(t, st.SetEnabled(false))
+	if on, err = st.IsEnabled(); err != nil || on {
+		t.Errorf("IsEnabled() = %v, %v after SetEnabled(false)", on, err)
+	}
+}
+
+func testChats(t *testing.T, st Store) {
+	const a, b = -1001, -1002
+	check(t, st.AddChat(b, DefaultChatDefaults))
+	check(t, st.AddChat(a, ChatDefaults{Paused: true, Reactors: 2, Comments: true,
+		Post: Rule{Probability: 50, Filter: FilterMedia}, Comment: DefaultRule, Albums: AlbumAll}))
+	want := Chat{ID: a, Reactors: 2, Comments: true, Post: Rule{Probability: 50, Filter: FilterMedia}, Comment: DefaultRule, Albums: AlbumAll}
+	if got, ok, err := st.GetChat(a); err != nil || !ok || got != want {
+		t.Errorf("GetChat(%d) = %+v, %v, %v, want %+v", int64(a), got, ok, err, want)
+	}
+	// Adding a chat again keeps its settings.
+	check(t, st.AddChat(a, DefaultChatDefaults))
+	if got, _, _ := st.GetChat(a); got != want {
+		t.Errorf("re-adding a chat changed it to %+v", got)
+	}
+	if err := st.AddChat(-1003, ChatDefaults{Reactors: -1, Post: DefaultRule, Comment: DefaultRule, Albums: AlbumFirst}); err == nil {
+		t.Error("AddChat accepted a negative reactor count")
+	}
+	if err := st.AddChat(-1003, ChatDefaults{Post: Rule{Probability: 0, Filter: FilterAll}, Comment: DefaultRule, Albums: AlbumFirst}); err == nil {
+		t.Error("AddChat accepted a 0% rule")
+	}
+	if err := st.AddChat(-1003, ChatDefaults{Post: DefaultRule, Comment: DefaultRule, Albums: "most"}); err == nil {
+		t.Error("AddChat accepted an unknown album mode")
+	}
+
+	ids, err := st.GetChats()
+	check(t, err)
+	if !reflect.DeepEqual(sorted(ids), []int64{b, a}) {
+		t.Errorf("GetChats() = %v", ids)
+	}
+	list, err := st.ListChats()
+	check(t, err)
+	if want := []Chat{{ID: b, Enabled: true, Post: DefaultRule, Comment: DefaultRule, Albums: DefaultAlbumMode}, want}; !reflect.DeepEqual(list, want) {
+		t.Errorf("ListChats() = %+v, want %+v", list, want)
+	}
+
+	if has, err := st.HasChat(a); err != nil || !has {
+		t.Errorf("HasChat(%d) = %v, %v", a, has, err)
+	}
+	if active, err := st.IsChatActive(a); err != nil || active {
+		t.Errorf("IsChatActive(paused chat) = %v, %v", active, err)
+	}
+	check(t, st.SetChatEnabled(a, true))
+	if active, err := st.IsChatActive(a); err != nil || !active {
+		t.Errorf("IsChatActive(resumed chat) = %v, %v", active, err)
+	}
+	if err := st.SetChatEnabled(42, false); err != ErrChatNotFound {
+		t.Errorf("SetChatEnabled(unknown chat) = %v, want ErrChatNotFound", err)
+	}
+	check(t, st.SetChatReactors(b, 3))
+	if n, err := st.ChatReactors(b); err != nil || n != 3 {
+		t.Errorf("ChatReactors() = %d, %v, want 3", n, err)
+	}
+	if n, err := st.ChatReactors(-42); err != nil || n != 0 {
+		t.Errorf("ChatReactors(unknown chat) = %d, %v, want 0", n, err)
+	}
+	if err := st.SetChatReactors(-42, 1); err != ErrChatNotFound {
+		t.Errorf("SetChatReactors(unknown chat) = %v, want ErrChatNotFound", err)
+	}
+
+	check(t, st.RemoveChat(a))
+	if has, err := st.HasChat(a); err != nil || has {
+		t.Errorf("HasChat(removed chat) = %v, %v", has, err)
+	}
+	if _, ok, err := st.GetChat(a); err != nil || ok {
+		t.Errorf("GetChat(removed chat) = %v, %v", ok, err)
+	}
+}
+
+func testChatSettings(t *testing.T, st Store) {
+	const channel, group = -1001, -1009
+	check(t, st.AddChat(channel, DefaultChatDefaults))
+
+	rule := Rule{Probability: 25, Filter: FilterText}
+	check(t, st.SetChatRule(channel, KindComment, rule))
+	check(t, st.SetChatAlbums(channel, AlbumCaption))
+	c, _, err := st.GetChat(channel)
+	check(t, err)
+	if c.Rule(KindComment) != rule || c.Rule(KindPost) != DefaultRule || c.Albums != AlbumCaption {
+		t.Errorf("GetChat() = %+v after setting the comment rule and albums", c)
+	}
+	if err := st.SetChatRule(channel, KindPost, Rule{Probability: 101, Filter: FilterAll}); err == nil {
+		t.Error("SetChatRule accepted a 101% rule")
+	}
+	if err := st.SetChatRule(-42, KindPost, DefaultRule); !errors.Is(err, ErrChatNotFound) {
+		t.Errorf("SetChatRule(unknown chat) = %v, want ErrChatNotFound", err)
+	}
+	if err := st.SetChatAlbums(-42, AlbumAll); !errors.Is(err, ErrChatNotFound) {
+		t.Errorf("SetChatAlbums(unknown chat) = %v, want ErrChatNotFound", err)
+	}
+
+	// The discussion group leads back to the channel while comments are on;
+	// toggling comments forgets it so it is looked up again.
+	check(t, st.SetChatComments(channel, true))
+	check(t, st.SetChatDiscussion(channel, group))
+	if parent, ok, err := st.DiscussionParent(group); err != nil || !ok || parent != channel {
+		t.Errorf("DiscussionParent() = %d, %v, %v, want %d", parent, ok, err, int64(channel))
+	}
+	check(t, st.SetChatComments(channel, false))
+	if _, ok, err := st.DiscussionParent(group); err != nil || ok {
+		t.Errorf("DiscussionParent() with comments off = %v, %v", ok, err)
+	}
+}
+
+func testEmojis(t *testing.T, st Store) {
+	prem, err := st.GetPremEmojis()
+	check(t, err)
+	nprem, err := st.GetNpremEmojis()
+	check(t, err)
+	if !reflect.DeepEqual(sorted(prem), sorted(defaultEmojis["prem_emojis"])) ||
+		!reflect.DeepEqual(sorted(nprem), sorted(defaultEmojis["nprem_emojis"])) {
+		t.Errorf("default pools = %v, %v", prem, nprem)
+	}
+
+	check(t, st.AddPremEmoji("🤯"))
+	check(t, st.AddPremEmoji("🤯"))
+	check(t, st.RemoveNpremEmoji("🔥"))
+	prem, _ = st.GetPremEmojis()
+	nprem, _ = st.GetNpremEmojis()
+	if n := strings.Count(strings.Join(prem, " "), "🤯"); n != 1 {
+		t.Errorf("premium pool holds 🤯 %d times, want once", n)
+	}
+	if slices.Contains(nprem, "🔥") {
+		t.Errorf("non-premium pool %v still holds 🔥", nprem)
+	}
+
+	if c, err := st.GetCommentEmojis(true); err != nil || len(c) != 0 {
+		t.Errorf("comment pool starts as %v, %v, want empty", c, err)
+	}
+	check(t, st.AddCommentEmoji(true, "💯"))
+	check(t, st.AddCommentEmoji(false, "👏"))
+	if c, _ := st.GetCommentEmojis(true); !reflect.DeepEqual(c, []string{"💯"}) {
+		t.Errorf("premium comment pool = %v", c)
+	}
+	check(t, st.RemoveCommentEmoji(false, "👏"))
+	if c, _ := st.GetCommentEmojis(false); len(c) != 0 {
+		t.Errorf("non-premium comment pool = %v after removing its only emoji", c)
+	}
+}
+
+func testAssignments(t *testing.T, st Store) {
+	const a, b = -1001, -1002
+	check(t, st.AddChat(a, DefaultChatDefaults))
+	check(t, st.AddChat(b, DefaultChatDefaults))
+	check(t, st.AssignSessions(a, []string{"s2", "#eu", "s1", "s1"}))
+	check(t, st.AssignSessions(b, []string{"s3"}))
+
+	if got, err := st.ChatSessions(a); err != nil || !reflect.DeepEqual(got, []string{"#eu", "s1", "s2"}) {
+		t.Errorf("ChatSessions() = %v, %v", got, err)
+	}
+	check(t, st.UnassignSessions(a, []string{"s2"}))
+	all, err := st.AllChatSessions()
+	check(t, err)
+	if want := map[int64][]string{a: {"#eu", "s1"}, b: {"s3"}}; !reflect.DeepEqual(all, want) {
+		t.Errorf("AllChatSessions() = %v, want %v", all, want)
+	}
+
+	check(t, st.UnassignSessions(a, nil))
+	if got, _ := st.ChatSessions(a); len(got) != 0 {
+		t.Errorf("ChatSessions() = %v after unassigning all", got)
+	}
+	check(t, st.RemoveChat(b))
+	if all, _ := st.AllChatSessions(); len(all) != 0 {
+		t.Errorf("AllChatSessions() = %v after removing the chat", all)
+	}
+}
+
+func testTenants(t *testing.T, st Store) {
+	other := st.ForTenant("acme")
+	if other.Tenant() != "acme" || st.Tenant() != DefaultTenant {
+		t.Fatalf("tenants = %q, %q", st.Tenant(), other.Tenant())
+	}
+	check(t, other.InitTenant())
+	if prem, _ := other.GetPremEmojis(); !reflect.DeepEqual(sorted(prem), sorted(defaultEmojis["prem_emojis"])) {
+		t.Errorf("new tenant's premium pool = %v", prem)
+	}
+	// Initialising again must not undo changes.
+	check(t, other.RemovePremEmoji("🐳"))
+	check(t, other.InitTenant())
+	if prem, _ := other.GetPremEmojis(); slices.Contains(prem, "🐳") {
+		t.Error("InitTenant re-seeded an initialised tenant")
+	}
+
+	check(t, st.AddChat(-1001, DefaultChatDefaults))
+	check(t, other.SetEnabled(false))
+	check(t, other.AddNpremEmoji("🦄"))
+	if has, _ := other.HasChat(-1001); has {
+		t.Error("a chat leaked into another tenant")
+	}
+	if on, _ := st.IsEnabled(); !on {
+		t.Error("disabling one tenant disabled another")
+	}
+	if nprem, _ := st.GetNpremEmojis(); slices.Contains(nprem, "🦄") {
+		t.Error("an emoji leaked into another tenant")
+	}
+	if err := other.SetChatEnabled(-1001, false); !errors.Is(err, ErrChatNotFound) {
+		t.Errorf("SetChatEnabled on another tenant's chat = %v, want ErrChatNotFound", err)
+	}
+}
+
+func testMigrateChat(t *testing.T, st Store) {
+	const from, to = -42, -1000000000042
+	other := st.ForTenant("acme")
+	check(t, other.InitTenant())
+	check(t, st.AddChat(from, ChatDefaults{Paused: true, Reactors: 2, Comments: true,
+		Post: DefaultRule, Comment: Rule{Probability: 40, Filter: FilterText}, Albums: AlbumCaption}))
+	check(t, other.AddChat(from, DefaultChatDefaults))
+	check(t, st.AddChatManager(from, 7))
+	check(t, st.AssignSessions(from, []string{"s1"}))
+
+	tenants, err := st.MigrateChat(from, to)
+	check(t, err)
+	if !reflect.DeepEqual(tenants, []string{"acme", DefaultTenant}) {
+		t.Errorf("MigrateChat() moved %v", tenants)
+	}
+	want := Chat{ID: to, Reactors: 2, Comments: true, Post: DefaultRule, Comment: Rule{Probability: 40, Filter: FilterText}, Albums: AlbumCaption}
+	if c, ok, err := st.GetChat(to); err != nil || !ok || c != want {
+		t.Errorf("migrated chat = %+v, %v, %v, want %+v", c, ok, err, want)
+	}
+	if has, _ := st.HasChat(from); has {
+		t.Error("the old ID is still monitored")
+	}
+	if has, _ := other.HasChat(to); !has {
+		t.Error("the chat did not move in the other tenant")
+	}
+	if m, _ := st.IsChatManager(to, 7); !m {
+		t.Error("the manager did not move")
+	}
+	if s, _ := st.ChatSessions(to); !reflect.DeepEqual(s, []string{"s1"}) {
+		t.Errorf("assigned sessions = %v after moving", s)
+	}
+	if tenants, err := st.MigrateChat(from, to); err != nil || len(tenants) != 0 {
+		t.Errorf("migrating again = %v, %v, want nothing moved", tenants, err)
+	}
+}
+
+func testUsers(t *testing.T, st Store) {
+	check(t, st.SetUserRole(1, RoleOwner, 0))
+	check(t, st.SetUserRole(2, RoleViewer, 1))
+	check(t, st.SetUserRole(2, RoleAdmin, 1))
+	if role, ok, err := st.GetUserRole(2); err != nil || !ok || role != RoleAdmin {
+		t.Errorf("GetUserRole(2) = %q, %v, %v", role, ok, err)
+	}
+	users, err := st.ListUsers()
+	check(t, err)
+	if len(users) != 2 || users[0].ID != 1 || users[1].ID != 2 || users[1].GrantedBy != 1 {
+		t.Errorf("ListUsers() = %+v, want ordered by rank", users)
+	}
+
+	other := st.ForTenant("acme")
+	if err := other.SetUserRole(2, RoleOwner, 0); !errors.Is(err, ErrOtherTenant) {
+		t.Errorf("granting a role in a second tenant = %v, want ErrOtherTenant", err)
+	}
+	if u, ok, err := other.FindUser(2); err != nil || !ok || u.Tenant != DefaultTenant || u.Role != RoleAdmin {
+		t.Errorf("FindUser(2) = %+v, %v, %v", u, ok, err)
+	}
+	if _, ok, _ := other.GetUserRole(2); ok {
+		t.Error("a role is visible in another tenant")
+	}
+
+	// Removing a user also ends what they manage, in their tenant only.
+	check(t, st.AddChat(-1001, DefaultChatDefaults))
+	check(t, st.AddChatManager(-1001, 2))
+	check(t, other.AddChatManager(-1001, 2))
+	if removed, err := st.RemoveUser(2); err != nil || !removed {
+		t.Errorf("RemoveUser(2) = %v, %v", removed, err)
+	}
+	if chats, _ := st.ManagedChats(2); len(chats) != 0 {
+		t.Errorf("ManagedChats(2) = %v after removing the user", chats)
+	}
+	if chats, _ := other.ManagedChats(2); len(chats) != 1 {
+		t.Errorf("ManagedChats(2) = %v in another tenant, want it kept", chats)
+	}
+	if removed, err := st.RemoveUser(2); err != nil || removed {
+		t.Errorf("RemoveUser(2) again = %v, %v", removed, err)
+	}
+	// A manager without a role is removed too.
+	check(t, st.AddChatManager(-1001, 3))
+	if removed, err := st.RemoveUser(3); err != nil || !removed {
+		t.Errorf("RemoveUser(manager only) = %v, %v", removed, err)
+	}
+}
+
+func testManagers(t *testing.T, st Store) {
+	const a, b = -1001, -1002
+	check(t, st.AddChat(a, DefaultChatDefaults))
+	check(t, st.AddChat(b, DefaultChatDefaults))
+	check(t, st.AddChatManager(a, 7))
+	check(t, st.AddChatManager(b, 7))
+	check(t, st.AddChatManager(b, 8))
+
+	if chats, err := st.ManagedChats(7); err != nil || !reflect.DeepEqual(sorted(chats), []int64{b, a}) {
+		t.Errorf("ManagedChats(7) = %v, %v", chats, err)
+	}
+	check(t, st.RemoveChatManager(b, 8))
+	if m, _ := st.IsChatManager(b, 8); m {
+		t.Error("a removed manager still manages the chat")
+	}
+	check(t, st.RemoveChat(a))
+	check(t, st.RemoveManager(8))
+	managers, err := st.ChatManagers()
+	check(t, err)
+	if want := map[int64][]int64{b: {7}}; !reflect.DeepEqual(managers, want) {
+		t.Errorf("ChatManagers() = %v, want %v", managers, want)
+	}
+}
+
+func testSessions(t *testing.T, st Store) {
+	check(t, st.RecordSession("s1", 100, true))
+	check(t, st.RecordSession("s2", 200, false))
+	check(t, st.RetireSession("s2", "revoked"))
+	check(t, st.RetireSession("s3", "never connected"))
+
+	rec, ok, err := st.GetSession("s1")
+	check(t, err)
+	if !ok || rec.UserID != 100 || !rec.IsPremium || rec.Retired {
+		t.Errorf("GetSession(s1) = %+v, %v", rec, ok)
+	}
+	rec, ok, _ = st.GetSession("s2")
+	if !ok || !rec.Retired || rec.Reason != "revoked" || rec.UserID != 200 {
+		t.Errorf("GetSession(s2) = %+v, %v", rec, ok)
+	}
+	if _, ok, _ := st.GetSession("missing"); ok {
+		t.Error("GetSession found a session that was never recorded")
+	}
+	all, err := st.GetSessions()
+	check(t, err)
+	if len(all) != 3 || all[0].ID != "s1" || all[2].ID != "s3" {
+		t.Errorf("GetSessions() = %+v", all)
+	}
+	check(t, st.TagSession("s1", []string{"eu", "fast"}))
+	check(t, st.TagSession("s2", []string{"eu"}))
+	check(t, st.UntagSession("s1", []string{"fast"}))
+	tags, err := st.SessionTags()
+	check(t, err)
+	if want := map[string][]string{"s1": {"eu"}, "s2": {"eu"}}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("SessionTags() = %v, want %v", tags, want)
+	}
+}
+
+func testUsage(t *testing.T, st Store) {
+	hour := time.Now().Truncate(time.Hour)
+	check(t, st.AddSessionUsage("s1", hour.Add(time.Minute), 2))
+	check(t, st.AddSessionUsage("s1", hour.Add(30*time.Minute), 3))
+	check(t, st.AddSessionUsage("s2", hour.Add(-time.Hour), 1))
+	// Buckets older than the retention are dropped.
+	check(t, st.AddSessionUsage("s2", hour.Add(-usageRetention-time.Hour), 9))
+
+	got, err := st.SessionUsage(hour.Add(-24 * time.Hour))
+	check(t, err)
+	want := []UsageBucket{{"s1", hour, 5}, {"s2", hour.Add(-time.Hour), 1}}
+	if len(got) != len(want) {
+		t.Fatalf("SessionUsage() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i].SessionID != want[i].SessionID || !got[i].Hour.Equal(want[i].Hour) || got[i].Count != want[i].Count {
+			t.Errorf("bucket %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func testPendingReactions(t *testing.T, st Store) {
+	now := time.Now().Truncate(time.Second)
+	older := PendingReaction{Tenant: "acme", SessionID: "s2", ChatID: -1002, MsgID: 5, Kind: KindComment, QueuedAt: now.Add(-time.Minute)}
+	newer := PendingReaction{Tenant: DefaultTenant, SessionID: "s1", ChatID: -1001, MsgID: 9, Kind: KindPost, QueuedAt: now}
+	check(t, st.SavePendingReactions([]PendingReaction{newer, older}))
+	check(t, st.SavePendingReactions([]PendingReaction{newer}))
+
+	got, err := st.TakePendingReactions()
+	check(t, err)
+	if len(got) != 2 || !got[0].QueuedAt.Equal(older.QueuedAt) || !got[1].QueuedAt.Equal(newer.QueuedAt) {
+		t.Fatalf("TakePendingReactions() = %+v, want the two saved, oldest first", got)
+	}
+	got[0].QueuedAt, got[1].QueuedAt = older.QueuedAt, newer.QueuedAt
+	if got[0] != older || got[1] != newer {
+		t.Errorf("TakePendingReactions() = %+v", got)
+	}
+	if again, err := st.TakePendingReactions(); err != nil || len(again) != 0 {
+		t.Errorf("second TakePendingReactions() = %+v, %v, want none", again, err)
+	}
+}
+
+func testAudit(t *testing.T, st Store) {
+	for i := range 3 {
+		check(t, st.AddAudit(AuditEntry{At: time.Now(), UserID: 1, Command: fmt.Sprintf("/cmd%d", i)}))
+	}
+	if n, err := st.CountAudit(); err != nil || n != 3 {
+		t.Errorf("CountAudit() = %d, %v, want 3", n, err)
+	}
+	page, err := st.ListAudit(2, 1)
+	check(t, err)
+	if len(page) != 2 || page[0].Command != "/cmd1" || page[1].Command != "/cmd0" {
+		t.Errorf("ListAudit(2, 1) = %+v, want newest first", page)
+	}
+}
+
+func testExportImport(t *testing.T, st Store) {
+	const a, b = -1001, -1002
+	check(t, st.SetEnabled(false))
+	check(t, st.AddChat(a, ChatDefaults{Paused: true, Reactors: 2, Comments: true,
+		Post: Rule{Probability: 40, Filter: FilterText}, Comment: DefaultRule, Albums: AlbumAll}))
+	check(t, st.AddChat(b, DefaultChatDefaults))
+	check(t, st.AddChatManager(a, 7))
+	check(t, st.AssignSessions(a, []string{"#eu", "s1", "s2"}))
+	check(t, st.AddPremEmoji("💯"))
+	check(t, st.AddCommentEmoji(false, "👏"))
+	check(t, st.SetUserRole(7, RoleAdmin, 1))
+
+	snap, err := st.Export()
+	check(t, err)
+	for _, format := range []string{"json", "yaml"} {
+		data, err := snap.Marshal(format)
+		check(t, err)
+		parsed, err := ParseSnapshot(data)
+		check(t, err)
+
+		// Change the store, then replace it with the snapshot.
+		check(t, st.SetEnabled(true))
+		check(t, st.RemoveChat(b))
+		check(t, st.AddChat(-1003, DefaultChatDefaults))
+		check(t, st.AssignSessions(-1003, []string{"s9"}))
+		check(t, st.UnassignSessions(a, []string{"s2"}))
+		check(t, st.RemovePremEmoji("💯"))
+		check(t, st.RemoveCommentEmoji(false, "👏"))
+		check(t, st.SetChatRule(a, KindPost, DefaultRule))
+		check(t, st.SetUserRole(8, RoleViewer, 1))
+		changes, err := st.Import(parsed, ImportReplace, true)
+		check(t, err)
+		if len(changes) == 0 {
+			t.Errorf("%s: dry run reported no changes", format)
+		}
+		if has, _ := st.HasChat(-1003); !has {
+			t.Errorf("%s: dry run changed the store", format)
+		}
+		_, err = st.Import(parsed, ImportReplace, false)
+		check(t, err)
+
+		got, err := st.Export()
+		check(t, err)
+		want := *snap
+		got.ExportedAt, want.ExportedAt = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("%s: after import, export = %+v, want %+v", format, *got, want)
+		}
+		if all, _ := st.AllChatSessions(); len(all[-1003]) != 0 {
+			t.Errorf("%s: assignments of a replaced chat survived: %v", format, all)
+		}
+	}
+}
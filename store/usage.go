@@ -0,0 +1,51 @@
+package store
+
+import "time"
+
+// usageRetention is how long hourly usage buckets are kept. Quotas only look
+// at the current day.
+const usageRetention = 7 * 24 * time.Hour
+
+// UsageBucket counts the reactions one session sent in one clock hour.
+type UsageBucket struct {
+	SessionID string
+	Hour      time.Time
+	Count     int
+}
+
+// AddSessionUsage adds n reactions to the session's bucket for the hour
+// containing at. Usage is shared by all tenants, like the sessions.
+func (s *SQLStore) AddSessionUsage(id string, at time.Time, n int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(`INSERT INTO session_usage (session_id, hour, count) VALUES (?, ?, ?)
+ON CONFLICT(session_id, hour) DO UPDATE SET count = session_usage.count + excluded.count`,
+		id, at.Unix()/3600, n)
+	return err
+}
+
+// SessionUsage returns every bucket from since on, and drops buckets older
+// than the retention period while it is at it.
+func (s *SQLStore) SessionUsage(since time.Time) ([]UsageBucket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.db.Exec(`DELETE FROM session_usage WHERE hour < ?`, time.Now().Add(-usageRetention).Unix()/3600); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(`SELECT session_id, hour, count FROM session_usage WHERE hour >= ? ORDER BY session_id, hour`, since.Unix()/3600)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []UsageBucket
+	for rows.Next() {
+		var b UsageBucket
+		var hour int64
+		if err := rows.Scan(&b.SessionID, &hour, &b.Count); err != nil {
+			return nil, err
+		}
+		b.Hour = time.Unix(hour*3600, 0)
+		out = append(out, b)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleViewer Role = "viewer"
+)
+
+var roleRanks = map[Role]int{RoleViewer: 1, RoleAdmin: 2, RoleOwner: 3}
+
+func ParseRole(s string) (Role, error) {
+	r := Role(s)
+	if _, ok := roleRanks[r]; !ok {
+		return "", fmt.Errorf("unknown role %q: use owner, admin or viewer", s)
+	}
+	return r, nil
+}
+
+// AtLeast reports whether r grants every permission of min.
+func (r Role) AtLeast(min Role) bool {
+	return roleRanks[r] >= roleRanks[min]
+}
+
+type User struct {
+	ID        int64
+	Tenant    string
+	Role      Role
+	GrantedBy int64
+	GrantedAt time.Time
+}
+
+// FindUser looks a user up in every tenant; it is how the bot works out
+// which tenant a sender belongs to.
+func (s *SQLStore) FindUser(userID int64) (User, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u := User{ID: userID}
+	var role string
+	var granted int64
+	err := s.db.QueryRow(`SELECT tenant_id, role, granted_by, granted_at FROM users WHERE user_id = ?`, userID).
+		Scan(&u.Tenant, &role, &u.GrantedBy, &granted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, false, nil
+	}
+	if err != nil {
+		return User{}, false, err
+	}
+	u.Role = Role(role)
+	u.GrantedAt = time.Unix(granted, 0)
+	return u, true, nil
+}
+
+func (s *SQLStore) GetUserRole(userID int64) (Role, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var role string
+	err := s.db.QueryRow(`SELECT role FROM users WHERE tenant_id = ? AND user_id = ?`, s.tenant, userID).Scan(&role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return Role(role), true, nil
+}
+
+func (s *SQLStore) SetUserRole(userID int64, role Role, grantedBy int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, err := s.db.Exec(`INSERT INTO users (tenant_id, user_id, role, granted_by, granted_at) VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(user_id) DO UPDATE SET role = excluded.role, granted_by = excluded.granted_by, granted_at = excluded.granted_at
+WHERE users.tenant_id = excluded.tenant_id`,
+		s.tenant, userID, string(role), grantedBy, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrOtherTenant
+	}
+	return nil
+}
+
+// RemoveUser takes away the user's role and the chats they manage, together,
+// and reports whether they had either.
+func (s *SQLStore) RemoveUser(userID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tx, err := s.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+	var removed int64
+	for _, table := range []string{"users", "chat_managers"} {
+		res, err := tx.Exec(`DELETE FROM `+table+` WHERE tenant_id = ? AND user_id = ?`, s.tenant, userID)
+		if err != nil {
+			return false, err
+		}
+		n, _ := res.RowsAffected()
+		removed += n
+	}
+	return removed > 0, tx.Commit()
+}
+
+func (s *SQLStore) ListUsers() ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rows, err := s.db.Query(`SELECT u.user_id, u.role, u.granted_by, u.granted_at FROM users u
+JOIN roles r ON r.name = u.role WHERE u.tenant_id = ? ORDER BY r.rank DESC, u.user_id`, s.tenant)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var users []User
+	for rows.Next() {
+		var u User
+		var role string
+		var granted int64
+		if err := rows.Scan(&u.ID, &role, &u.GrantedBy, &granted); err != nil {
+			return nil, err
+		}
+		u.Tenant = s.tenant
+		u.Role = Role(role)
+		u.GrantedAt = time.Unix(granted, 0)
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}